@@ -23,17 +23,165 @@ package main
 //hard-coding.
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/chaincode_fileshare/basic/sumtree"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
 )
 
+const (
+	adminRole   = "admin"
+	arbiterRole = "arbiter"
+
+	rateSetterRole = "rateSetter"
+	issuerRole     = "issuer"
+
+	paymentPending  = "pending"
+	paymentDisputed = "disputed"
+	paymentSettled  = "settled"
+	paymentReversed = "reversed"
+
+	// default time a sender has to dispute a reversible payment
+	defaultDisputeWindow = 72 * 60 * 60
+
+	// asset held by the plain account balances
+	defaultAsset = "default"
+
+	accountActive    = "active"
+	accountOverdrawn = "overdrawn"
+
+	sortById          = "id"
+	sortByBalanceAsc  = "balanceAsc"
+	sortByBalanceDesc = "balanceDesc"
+
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+
+	// prefix of the reserves leaf holding a pending or disputed payment
+	escrowLeafPrefix = "escrow:"
+
+	roundDown     = "down"
+	roundUp       = "up"
+	roundHalfEven = "halfEven"
+)
+
 // SimpleChaincode example simple Chaincode implementation
 type SimpleChaincode struct {
 }
 
+// Payment is a reversible transfer waiting out its dispute window
+type Payment struct {
+	Id              string `json:"id"`
+	Sender          string `json:"sender"`
+	Recipient       string `json:"recipient"`
+	Amount          int    `json:"amount"`
+	Submitter       string `json:"submitter"`
+	Created         int64  `json:"created"`
+	DisputeDeadline int64  `json:"disputeDeadline"`
+	Status          string `json:"status"`
+	ResolvedBy      string `json:"resolvedBy,omitempty"`
+	Lots            []Lot  `json:"lots,omitempty"`
+}
+
+// Lot is a dated part of a balance. Lots are spent oldest first and removed
+// by the expire sweep once Expires has passed.
+type Lot struct {
+	Id      string `json:"id"`
+	Amount  int    `json:"amount"`
+	Issued  int64  `json:"issued"`
+	Expires int64  `json:"expires"`
+}
+
+// LotBreakdown is an account balance split into its lots
+type LotBreakdown struct {
+	Id        string `json:"id"`
+	Value     int    `json:"value"`
+	Untracked int    `json:"untracked"`
+	Lots      []Lot  `json:"lots"`
+}
+
+// Checkpoint labels a snapshot of every balance taken at Timestamp
+type Checkpoint struct {
+	Label     string `json:"label"`
+	TxId      string `json:"txid"`
+	Timestamp int64  `json:"timestamp"`
+	Accounts  int    `json:"accounts"`
+}
+
+// Reserves is a published Merkle sum tree root over every balance and every
+// payment held in escrow. Overdrawn balances count as 0 in the tree and what
+// they owe is reported as Overdrawn.
+type Reserves struct {
+	Id        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+	RootHash  string `json:"rootHash"`
+	Total     int64  `json:"total"`
+	Accounts  int    `json:"accounts"`
+	Escrow    int64  `json:"escrow"`
+	Overdrawn int64  `json:"overdrawn"`
+}
+
+// Rate converts units of From into units of To. The spread, in basis points,
+// is kept back from the converted amount before rounding.
+type Rate struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Rate      string `json:"rate"`
+	SpreadBps int    `json:"spreadBps"`
+	Rounding  string `json:"rounding"`
+	SetBy     string `json:"setBy"`
+	Updated   int64  `json:"updated"`
+}
+
+// Exchange records both legs of a conversion between assets
+type Exchange struct {
+	Id        string `json:"id"`
+	Account   string `json:"account"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Debited   int    `json:"debited"`
+	Credited  int    `json:"credited"`
+	Rate      string `json:"rate"`
+	SpreadBps int    `json:"spreadBps"`
+	Rounding  string `json:"rounding"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Account is the view of a balance returned by queryAccounts
+type Account struct {
+	Id     string `json:"id"`
+	Asset  string `json:"asset"`
+	Value  int    `json:"value"`
+	Status string `json:"status"`
+}
+
+// AccountFilter selects, orders and pages the accounts returned by queryAccounts
+type AccountFilter struct {
+	MinBalance *int   `json:"minBalance"`
+	MaxBalance *int   `json:"maxBalance"`
+	Status     string `json:"status"`
+	Asset      string `json:"asset"`
+	Sort       string `json:"sort"`
+	Limit      int    `json:"limit"`
+	Bookmark   string `json:"bookmark"`
+}
+
+// AccountPage is one page of queryAccounts results. An empty bookmark means
+// there are no further pages.
+type AccountPage struct {
+	Accounts []Account `json:"accounts"`
+	Bookmark string    `json:"bookmark"`
+}
+
 func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	fmt.Println("ex02 Init")
 	_, args := stub.GetFunctionAndParameters()
@@ -69,6 +217,16 @@ func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 		return shim.Error(err.Error())
 	}
 
+	// The instantiating identity administers roles and configuration
+	admin, err := getCallerId(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putRole(stub, adminRole, admin)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	return shim.Success(nil)
 }
 
@@ -86,25 +244,76 @@ func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.query(stub, args)
 	} else if function == "findAll" {
 		return t.findAll(stub)
+	} else if function == "moveReversible" {
+		// Make a payment of X units from A to B that A may dispute
+		return t.moveReversible(stub, args)
+	} else if function == "dispute" {
+		return t.dispute(stub, args)
+	} else if function == "resolve" {
+		return t.resolve(stub, args)
+	} else if function == "release" {
+		return t.release(stub, args)
+	} else if function == "queryPayment" {
+		return t.queryPayment(stub, args)
+	} else if function == "queryPending" {
+		return t.queryPending(stub, args)
+	} else if function == "setDisputeWindow" {
+		return t.setDisputeWindow(stub, args)
+	} else if function == "assignRole" {
+		return t.assignRole(stub, args)
+	} else if function == "queryAccounts" {
+		return t.queryAccounts(stub, args)
+	} else if function == "checkpoint" {
+		return t.checkpoint(stub, args)
+	} else if function == "balanceAt" {
+		return t.balanceAt(stub, args)
+	} else if function == "queryCheckpoint" {
+		return t.queryCheckpoint(stub, args)
+	} else if function == "publishReserves" {
+		return t.publishReserves(stub, args)
+	} else if function == "queryReserves" {
+		return t.queryReserves(stub, args)
+	} else if function == "proveReserves" {
+		return t.proveReserves(stub, args)
+	} else if function == "exchange" {
+		return t.exchange(stub, args)
+	} else if function == "setRate" {
+		return t.setRate(stub, args)
+	} else if function == "queryRate" {
+		return t.queryRate(stub, args)
+	} else if function == "queryBalances" {
+		return t.queryBalances(stub, args)
+	} else if function == "issue" {
+		return t.issue(stub, args)
+	} else if function == "expire" {
+		return t.expire(stub, args)
+	} else if function == "queryLots" {
+		return t.queryLots(stub, args)
+	} else if function == "setLotPeriod" {
+		return t.setLotPeriod(stub, args)
 	}
 
-	return shim.Error("Invalid invoke function name. Expecting \"move\" \"delete\" \"query\" \"findAll\"")
+	return shim.Error("Invalid invoke function name. Expecting \"move\" \"delete\" \"query\" \"findAll\" \"queryAccounts\" \"checkpoint\" \"balanceAt\" \"queryCheckpoint\" \"publishReserves\" \"queryReserves\" \"proveReserves\" \"exchange\" \"setRate\" \"queryRate\" \"queryBalances\" \"issue\" \"expire\" \"queryLots\" \"setLotPeriod\" \"moveReversible\" \"dispute\" \"resolve\" \"release\" \"queryPayment\" \"queryPending\" \"setDisputeWindow\" \"assignRole\"")
 }
 
-// Transaction makes payment of X units from A to B
+// Transaction makes payment of X units from A to B, optionally of a named asset
 func (t *SimpleChaincode) move(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	var A, B string    // Entities
 	var Aval, Bval int // Asset holdings
 	var X int          // Transaction value
 	var err error
 
-	if len(args) != 3 {
-		return shim.Error("Incorrect number of arguments. Expecting 3")
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 3 or 4")
 	}
 
 	A = args[0]
 	B = args[1]
 
+	if len(args) == 4 && args[3] != defaultAsset {
+		return t.moveAsset(stub, A, B, args[3], args[2])
+	}
+
 	// Get the state from the ledger
 	// TODO: will be nice to have a GetAllState call to ledger
 	Avalbytes, err := stub.GetState(A)
@@ -130,6 +339,17 @@ func (t *SimpleChaincode) move(stub shim.ChaincodeStubInterface, args []string)
 	if err != nil {
 		return shim.Error("Invalid transaction amount, expecting a integer value")
 	}
+
+	// A spends its oldest lots first and B receives them with their expiry
+	lots, err := consumeLots(stub, A, Aval, X)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = creditLots(stub, B, lots)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	Aval = Aval - X
 	Bval = Bval + X
 	fmt.Printf("Aval = %d, Bval = %d\n", Aval, Bval)
@@ -162,6 +382,11 @@ func (t *SimpleChaincode) delete(stub shim.ChaincodeStubInterface, args []string
 		return shim.Error("Failed to delete state")
 	}
 
+	err = deleteLots(stub, A)
+	if err != nil {
+		return shim.Error("Failed to delete lots")
+	}
+
 	return shim.Success(nil)
 }
 
@@ -237,6 +462,1846 @@ func (t *SimpleChaincode) findAll(stub shim.ChaincodeStubInterface) pb.Response
 	return shim.Success(aAndBvalueResponse)
 }
 
+// queryAccounts returns a page of accounts matching a JSON AccountFilter, e.g.
+// {"sort":"balanceDesc","limit":50} or {"maxBalance":10,"bookmark":"..."}
+func (t *SimpleChaincode) queryAccounts(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	filter := AccountFilter{}
+
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting an optional filter")
+	}
+	if len(args) == 1 && strings.TrimSpace(args[0]) != "" {
+		err := json.Unmarshal([]byte(args[0]), &filter)
+		if err != nil {
+			return shim.Error("Invalid filter: " + err.Error())
+		}
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = sortById
+	}
+	if filter.Sort != sortById && filter.Sort != sortByBalanceAsc && filter.Sort != sortByBalanceDesc {
+		return shim.Error("Invalid sort " + filter.Sort + ". Expecting \"" + sortById + "\" \"" + sortByBalanceAsc + "\" \"" + sortByBalanceDesc + "\"")
+	}
+	if filter.Limit < 0 || filter.Limit > maxQueryLimit {
+		return shim.Error("Limit must be between 1 and " + strconv.Itoa(maxQueryLimit) + ", or 0 for the default of " + strconv.Itoa(defaultQueryLimit))
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultQueryLimit
+	}
+
+	var page AccountPage
+	var err error
+	if filter.Sort == sortById {
+		page, err = getAccountsById(stub, filter)
+	} else {
+		page, err = getAccountsByBalance(stub, filter)
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pageBytes, err := json.Marshal(page)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(pageBytes)
+}
+
+// getAccountsById pages through accounts in key order, resuming just after the
+// bookmarked key
+func getAccountsById(stub shim.ChaincodeStubInterface, filter AccountFilter) (AccountPage, error) {
+	page := AccountPage{Accounts: []Account{}}
+
+	err := scanAccounts(stub, filter.Asset, filter.Bookmark, func(account Account) bool {
+		if !filter.matches(account) {
+			return true
+		}
+		if len(page.Accounts) == filter.Limit {
+			page.Bookmark = page.Accounts[len(page.Accounts)-1].Id
+			return false
+		}
+		page.Accounts = append(page.Accounts, account)
+		return true
+	})
+
+	return page, err
+}
+
+// getAccountsByBalance scans every account and keeps the first filter.Limit
+// matches in balance order after the bookmark
+func getAccountsByBalance(stub shim.ChaincodeStubInterface, filter AccountFilter) (AccountPage, error) {
+	page := AccountPage{Accounts: []Account{}}
+
+	less := func(a, b Account) bool {
+		if a.Value != b.Value {
+			if filter.Sort == sortByBalanceDesc {
+				return a.Value > b.Value
+			}
+			return a.Value < b.Value
+		}
+		return a.Id < b.Id
+	}
+
+	var after *Account
+	if filter.Bookmark != "" {
+		parts := strings.SplitN(filter.Bookmark, ":", 2)
+		value, err := strconv.Atoi(parts[0])
+		if len(parts) != 2 || err != nil {
+			return page, fmt.Errorf("Invalid bookmark %s", filter.Bookmark)
+		}
+		after = &Account{Id: parts[1], Value: value}
+	}
+
+	// keep at most twice the page in memory, trimming back to one page more
+	// than the limit so we still know whether another page follows
+	matches := []Account{}
+	trim := func() {
+		sort.Slice(matches, func(i, j int) bool { return less(matches[i], matches[j]) })
+		if len(matches) > filter.Limit+1 {
+			matches = matches[:filter.Limit+1]
+		}
+	}
+
+	err := scanAccounts(stub, filter.Asset, "", func(account Account) bool {
+		if !filter.matches(account) {
+			return true
+		}
+		if after != nil && !less(*after, account) {
+			return true
+		}
+		matches = append(matches, account)
+		if len(matches) > 2*(filter.Limit+1) {
+			trim()
+		}
+		return true
+	})
+	if err != nil {
+		return page, err
+	}
+	trim()
+
+	if len(matches) > filter.Limit {
+		matches = matches[:filter.Limit]
+		last := matches[len(matches)-1]
+		page.Bookmark = strconv.Itoa(last.Value) + ":" + last.Id
+	}
+	page.Accounts = append(page.Accounts, matches...)
+
+	return page, nil
+}
+
+// scanAccounts calls visit for each holder of asset after afterKey until visit
+// returns false. The scan always starts at the first key since the mock stub
+// returns nothing for a range with a start key but no end key.
+func scanAccounts(stub shim.ChaincodeStubInterface, asset string, afterKey string, visit func(Account) bool) error {
+	if asset != "" && asset != defaultAsset {
+		return scanAssetAccounts(stub, asset, afterKey, visit)
+	}
+
+	resultsIterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+
+		// payments, roles and config live under composite keys, which a
+		// peer leaves out of range scans but the mock stub does not
+		if strings.HasPrefix(response.Key, "\x00") {
+			continue
+		}
+		if afterKey != "" && response.Key <= afterKey {
+			continue
+		}
+
+		value, err := strconv.Atoi(string(response.Value))
+		if err != nil {
+			continue
+		}
+
+		account := Account{}
+		account.Id = response.Key
+		account.Asset = defaultAsset
+		account.Value = value
+		account.Status = getAccountStatus(value)
+
+		if !visit(account) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// scanAssetAccounts walks the balances of a non-default asset after afterKey in
+// account order
+func scanAssetAccounts(stub shim.ChaincodeStubInterface, asset string, afterKey string, visit func(Account) bool) error {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("balance", []string{asset})
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+
+		_, keys, err := stub.SplitCompositeKey(response.Key)
+		if err != nil {
+			return err
+		}
+		if len(keys) != 2 || (afterKey != "" && keys[1] <= afterKey) {
+			continue
+		}
+
+		value, err := strconv.Atoi(string(response.Value))
+		if err != nil {
+			continue
+		}
+
+		account := Account{}
+		account.Id = keys[1]
+		account.Asset = asset
+		account.Value = value
+		account.Status = getAccountStatus(value)
+
+		if !visit(account) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func getAccountStatus(value int) string {
+	if value < 0 {
+		return accountOverdrawn
+	}
+
+	return accountActive
+}
+
+func (filter AccountFilter) matches(account Account) bool {
+	if filter.MinBalance != nil && account.Value < *filter.MinBalance {
+		return false
+	}
+	if filter.MaxBalance != nil && account.Value > *filter.MaxBalance {
+		return false
+	}
+	if filter.Status != "" && account.Status != filter.Status {
+		return false
+	}
+	if filter.Asset != "" && account.Asset != filter.Asset {
+		return false
+	}
+
+	return true
+}
+
+// checkpoint records every account balance under a label at the current
+// transaction timestamp
+func (t *SimpleChaincode) checkpoint(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting checkpoint label")
+	}
+
+	label := strings.TrimSpace(args[0])
+	if label == "" {
+		return shim.Error("A checkpoint label is required")
+	}
+
+	_, err := requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	checkpointKey, err := stub.CreateCompositeKey("checkpoint", []string{label})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	existing, err := stub.GetState(checkpointKey)
+	if err != nil {
+		return shim.Error("Failed to get checkpoint " + label)
+	}
+	if existing != nil {
+		return shim.Error("Checkpoint " + label + " already exists")
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	checkpoint := Checkpoint{}
+	checkpoint.Label = label
+	checkpoint.TxId = stub.GetTxID()
+	checkpoint.Timestamp = now
+
+	// one key per account so balanceAt is a single read
+	var putErr error
+	err = scanAccounts(stub, defaultAsset, "", func(account Account) bool {
+		var balanceKey string
+		balanceKey, putErr = stub.CreateCompositeKey("checkpointBalance", []string{label, account.Id})
+		if putErr != nil {
+			return false
+		}
+		putErr = stub.PutState(balanceKey, []byte(strconv.Itoa(account.Value)))
+		if putErr != nil {
+			return false
+		}
+		checkpoint.Accounts++
+		return true
+	})
+	if err == nil {
+		err = putErr
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	checkpointBytes, err := json.Marshal(checkpoint)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(checkpointKey, checkpointBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(checkpointBytes)
+}
+
+// balanceAt returns an account's balance as recorded by a named checkpoint
+func (t *SimpleChaincode) balanceAt(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting checkpoint label and name of the person to query")
+	}
+
+	label := args[0]
+	A := args[1]
+
+	checkpoint, err := getCheckpoint(stub, label)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	balanceKey, err := stub.CreateCompositeKey("checkpointBalance", []string{label, A})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	Avalbytes, err := stub.GetState(balanceKey)
+	if err != nil {
+		jsonResp := "{\"Error\":\"Failed to get checkpoint " + label + " state for " + A + "\"}"
+		return shim.Error(jsonResp)
+	}
+	if Avalbytes == nil {
+		jsonResp := "{\"Error\":\"No balance for " + A + " at checkpoint " + label + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	jsonResp := `{"id":"` + A + `", "value":` + string(Avalbytes) + `, "checkpoint":"` + label + `", "timestamp":` + strconv.FormatInt(checkpoint.Timestamp, 10) + `}`
+	fmt.Printf("Query Response:%s\n", jsonResp)
+
+	return shim.Success([]byte(jsonResp))
+}
+
+func (t *SimpleChaincode) queryCheckpoint(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting checkpoint label")
+	}
+
+	checkpoint, err := getCheckpoint(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	checkpointBytes, err := json.Marshal(checkpoint)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(checkpointBytes)
+}
+
+func getCheckpoint(stub shim.ChaincodeStubInterface, label string) (Checkpoint, error) {
+	checkpoint := Checkpoint{}
+
+	key, err := stub.CreateCompositeKey("checkpoint", []string{label})
+	if err != nil {
+		return checkpoint, err
+	}
+
+	checkpointBytes, err := stub.GetState(key)
+	if err != nil {
+		return checkpoint, fmt.Errorf("Failed to get checkpoint %s", label)
+	}
+	if checkpointBytes == nil {
+		return checkpoint, fmt.Errorf("Checkpoint not found: %s", label)
+	}
+
+	err = json.Unmarshal(checkpointBytes, &checkpoint)
+	return checkpoint, err
+}
+
+// publishReserves builds a Merkle sum tree over every balance and pending
+// payment and stores its root so account holders can prove they are counted
+// in the total
+func (t *SimpleChaincode) publishReserves(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+
+	_, err := requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// an overdrawn account owes the ledger rather than being owed by it, so
+	// it is a 0 leaf and its debt is reported beside the total
+	leaves := []sumtree.Leaf{}
+	var overdrawn int64
+	err = scanAccounts(stub, defaultAsset, "", func(account Account) bool {
+		value := int64(account.Value)
+		if value < 0 {
+			overdrawn = overdrawn - value
+			value = 0
+		}
+		leaves = append(leaves, sumtree.Leaf{Id: account.Id, Value: value})
+		return true
+	})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	reserves := Reserves{}
+	reserves.Id = stub.GetTxID()
+	reserves.Timestamp = now
+	reserves.Accounts = len(leaves)
+	reserves.Overdrawn = overdrawn
+
+	// pending and disputed payments have left the sender but not reached the
+	// recipient, so each is a leaf of its own
+	escrowLeaves, err := getEscrowLeaves(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, leaf := range escrowLeaves {
+		reserves.Escrow = reserves.Escrow + leaf.Value
+	}
+	leaves = append(leaves, escrowLeaves...)
+
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].Id < leaves[j].Id })
+	for i := 1; i < len(leaves); i++ {
+		if leaves[i].Id == leaves[i-1].Id {
+			return shim.Error("Account " + leaves[i].Id + " clashes with an escrow leaf")
+		}
+	}
+
+	// the tx id doubles as the tree's nonce
+	root, err := sumtree.Root(reserves.Id, leaves)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	reserves.RootHash = hex.EncodeToString(root.Hash)
+	reserves.Total = root.Sum
+
+	leavesBytes, err := json.Marshal(leaves)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	leavesKey, err := stub.CreateCompositeKey("reservesLeaves", []string{reserves.Id})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(leavesKey, leavesBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	reservesBytes, err := json.Marshal(reserves)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	reservesKey, err := stub.CreateCompositeKey("reserves", []string{reserves.Id})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(reservesKey, reservesBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = putConfig(stub, "latestReserves", reserves.Id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(reservesBytes)
+}
+
+// queryReserves returns a published root, the latest one if no id is given
+func (t *SimpleChaincode) queryReserves(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting an optional reserves id")
+	}
+
+	id, err := getReservesId(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	reservesKey, err := stub.CreateCompositeKey("reserves", []string{id})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	reservesBytes, err := stub.GetState(reservesKey)
+	if err != nil {
+		return shim.Error("Failed to get reserves " + id)
+	}
+	if reservesBytes == nil {
+		return shim.Error("Reserves not found: " + id)
+	}
+
+	return shim.Success(reservesBytes)
+}
+
+// proveReserves returns the inclusion proof of an account's balance in a
+// published root, the latest one if no id is given. A payment held in escrow
+// is proven as "escrow:<payment id>". The proof can be checked off-chain with
+// sumtree.Verify against the root and total queryReserves returns.
+func (t *SimpleChaincode) proveReserves(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 1 || len(args) > 2 {
+		return shim.Error("Incorrect number of arguments. Expecting name of the person to prove and an optional reserves id")
+	}
+
+	A := args[0]
+
+	id, err := getReservesId(stub, args[1:])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	leavesKey, err := stub.CreateCompositeKey("reservesLeaves", []string{id})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	leavesBytes, err := stub.GetState(leavesKey)
+	if err != nil {
+		return shim.Error("Failed to get reserves " + id)
+	}
+	if leavesBytes == nil {
+		return shim.Error("Reserves not found: " + id)
+	}
+
+	leaves := []sumtree.Leaf{}
+	err = json.Unmarshal(leavesBytes, &leaves)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// leaves are stored sorted by id
+	index := sort.Search(len(leaves), func(i int) bool { return leaves[i].Id >= A })
+	if index == len(leaves) || leaves[index].Id != A {
+		return shim.Error("No balance for " + A + " in reserves " + id)
+	}
+
+	proof, err := sumtree.Prove(id, leaves, index)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	proofBytes, err := json.Marshal(proof)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(proofBytes)
+}
+
+// getEscrowLeaves returns a reserves leaf for every pending or disputed payment
+func getEscrowLeaves(stub shim.ChaincodeStubInterface) ([]sumtree.Leaf, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("pending", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	leaves := []sumtree.Leaf{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		payment, err := getPayment(stub, string(response.Value))
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, sumtree.Leaf{Id: escrowLeafPrefix + payment.Id, Value: int64(payment.Amount)})
+	}
+
+	return leaves, nil
+}
+
+func getReservesId(stub shim.ChaincodeStubInterface, args []string) (string, error) {
+	if len(args) == 1 && args[0] != "" {
+		return args[0], nil
+	}
+
+	id, err := getConfig(stub, "latestReserves")
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("No reserves have been published")
+	}
+
+	return id, nil
+}
+
+// moveAsset makes a payment of amount units of a non-default asset from A to B
+func (t *SimpleChaincode) moveAsset(stub shim.ChaincodeStubInterface, A string, B string, asset string, amount string) pb.Response {
+	X, err := strconv.Atoi(amount)
+	if err != nil {
+		return shim.Error("Invalid transaction amount, expecting a integer value")
+	}
+	if X < 1 {
+		return shim.Error("Invalid transaction amount, expecting a positive value")
+	}
+
+	Aval, err := getAssetBalance(stub, A, asset)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	Bval, err := getAssetBalance(stub, B, asset)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if Aval < X {
+		return shim.Error("Insufficient " + asset + " balance for " + A)
+	}
+
+	Aval = Aval - X
+	Bval = Bval + X
+	fmt.Printf("Aval = %d, Bval = %d %s\n", Aval, Bval, asset)
+
+	err = putAssetBalance(stub, A, asset, Aval)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putAssetBalance(stub, B, asset, Bval)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// exchange converts an amount of one asset held by an account into another at
+// the stored rate, less the spread, rounded by the rate's rounding rule
+func (t *SimpleChaincode) exchange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting account, from asset, to asset and amount")
+	}
+
+	A := args[0]
+	from := args[1]
+	to := args[2]
+
+	if from == to {
+		return shim.Error("Can not exchange " + from + " for itself")
+	}
+
+	X, err := strconv.Atoi(args[3])
+	if err != nil {
+		return shim.Error("Invalid transaction amount, expecting a integer value")
+	}
+	if X < 1 {
+		return shim.Error("Invalid transaction amount, expecting a positive value")
+	}
+
+	rate, err := getRate(stub, from, to)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	credited, err := convertAmount(X, rate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if credited < 1 {
+		return shim.Error("Amount " + args[3] + " " + from + " is too small to exchange for " + to)
+	}
+
+	fromVal, err := getAssetBalance(stub, A, from)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if fromVal < X {
+		return shim.Error("Insufficient " + from + " balance for " + A)
+	}
+	toVal, err := getAssetBalance(stub, A, to)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// lots only track the default asset; units exchanged into it are new lots
+	if from == defaultAsset {
+		_, err = consumeLots(stub, A, fromVal, X)
+	} else if to == defaultAsset {
+		err = addNewLot(stub, A, credited)
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// both legs are written in this transaction so they commit together
+	err = putAssetBalance(stub, A, from, fromVal-X)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putAssetBalance(stub, A, to, toVal+credited)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	exchange := Exchange{}
+	exchange.Id = stub.GetTxID()
+	exchange.Account = A
+	exchange.From = from
+	exchange.To = to
+	exchange.Debited = X
+	exchange.Credited = credited
+	exchange.Rate = rate.Rate
+	exchange.SpreadBps = rate.SpreadBps
+	exchange.Rounding = rate.Rounding
+	exchange.Timestamp = now
+
+	exchangeBytes, err := json.Marshal(exchange)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	exchangeKey, err := stub.CreateCompositeKey("exchange", []string{exchange.Id})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(exchangeKey, exchangeBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent("exchange", exchangeBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(exchangeBytes)
+}
+
+// setRate stores the rate for converting one asset into another, e.g.
+// {"from":"default","to":"EUR","rate":"0.92","spreadBps":25,"rounding":"down"}
+func (t *SimpleChaincode) setRate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting rate")
+	}
+
+	caller, err := requireRole(stub, rateSetterRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	rate := Rate{}
+	err = json.Unmarshal([]byte(args[0]), &rate)
+	if err != nil {
+		return shim.Error("Invalid rate: " + err.Error())
+	}
+
+	if rate.Rounding == "" {
+		rate.Rounding = roundDown
+	}
+	err = verifyValidRate(rate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	rate.SetBy = caller
+	rate.Updated = now
+
+	rateBytes, err := json.Marshal(rate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	rateKey, err := stub.CreateCompositeKey("rate", []string{rate.From, rate.To})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(rateKey, rateBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(rateBytes)
+}
+
+func (t *SimpleChaincode) queryRate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting from asset and to asset")
+	}
+
+	rate, err := getRate(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	rateBytes, err := json.Marshal(rate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(rateBytes)
+}
+
+// queryBalances returns every asset balance held by an account
+func (t *SimpleChaincode) queryBalances(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting name of the person to query")
+	}
+
+	A := args[0]
+
+	value, err := getBalance(stub, A)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	balances := map[string]int{defaultAsset: value}
+
+	// asset balances are keyed by asset first, so walk the assets with rates
+	assets, err := getRateAssets(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, asset := range assets {
+		if asset == defaultAsset {
+			continue
+		}
+		value, err = getAssetBalance(stub, A, asset)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if value != 0 {
+			balances[asset] = value
+		}
+	}
+
+	balancesBytes, err := json.Marshal(balances)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success([]byte(`{"id":"` + A + `", "balances":` + string(balancesBytes) + `}`))
+}
+
+func verifyValidRate(rate Rate) error {
+	if strings.TrimSpace(rate.From) == "" || strings.TrimSpace(rate.To) == "" {
+		return fmt.Errorf("A rate needs a from and a to asset")
+	}
+	if rate.From == rate.To {
+		return fmt.Errorf("A rate must convert between two different assets")
+	}
+	value, ok := new(big.Rat).SetString(rate.Rate)
+	if !ok || value.Sign() <= 0 {
+		return fmt.Errorf("Rate %s must be a positive decimal", rate.Rate)
+	}
+	if rate.SpreadBps < 0 || rate.SpreadBps >= 10000 {
+		return fmt.Errorf("Spread must be between 0 and 9999 basis points")
+	}
+	if rate.Rounding != roundDown && rate.Rounding != roundUp && rate.Rounding != roundHalfEven {
+		return fmt.Errorf("Invalid rounding %s. Expecting \"%s\" \"%s\" \"%s\"", rate.Rounding, roundDown, roundUp, roundHalfEven)
+	}
+
+	return nil
+}
+
+// convertAmount applies rate and spread to amount and rounds to a whole unit
+func convertAmount(amount int, rate Rate) (int, error) {
+	value, ok := new(big.Rat).SetString(rate.Rate)
+	if !ok {
+		return 0, fmt.Errorf("Invalid stored rate %s", rate.Rate)
+	}
+
+	converted := new(big.Rat).SetInt64(int64(amount))
+	converted.Mul(converted, value)
+	converted.Mul(converted, big.NewRat(int64(10000-rate.SpreadBps), 10000))
+
+	quotient, remainder := new(big.Int).QuoRem(converted.Num(), converted.Denom(), new(big.Int))
+	if remainder.Sign() != 0 {
+		if rate.Rounding == roundUp {
+			quotient.Add(quotient, big.NewInt(1))
+		} else if rate.Rounding == roundHalfEven {
+			// compare twice the remainder with the denominator
+			half := new(big.Int).Lsh(remainder, 1).Cmp(converted.Denom())
+			if half > 0 || (half == 0 && quotient.Bit(0) == 1) {
+				quotient.Add(quotient, big.NewInt(1))
+			}
+		}
+	}
+
+	if !quotient.IsInt64() || quotient.Int64() > math.MaxInt32 {
+		return 0, fmt.Errorf("Converted amount is too large")
+	}
+
+	return int(quotient.Int64()), nil
+}
+
+func getRate(stub shim.ChaincodeStubInterface, from string, to string) (Rate, error) {
+	rate := Rate{}
+
+	rateKey, err := stub.CreateCompositeKey("rate", []string{from, to})
+	if err != nil {
+		return rate, err
+	}
+	rateBytes, err := stub.GetState(rateKey)
+	if err != nil {
+		return rate, fmt.Errorf("Failed to get rate from %s to %s", from, to)
+	}
+	if rateBytes == nil {
+		return rate, fmt.Errorf("No rate from %s to %s", from, to)
+	}
+
+	err = json.Unmarshal(rateBytes, &rate)
+	return rate, err
+}
+
+// getRateAssets lists every asset named by a stored rate
+func getRateAssets(stub shim.ChaincodeStubInterface) ([]string, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("rate", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	seen := map[string]bool{}
+	assets := []string{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keys, err := stub.SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, asset := range keys {
+			if !seen[asset] {
+				seen[asset] = true
+				assets = append(assets, asset)
+			}
+		}
+	}
+
+	sort.Strings(assets)
+	return assets, nil
+}
+
+// getAssetBalance returns an account's holding of asset. The default asset
+// is the plain account value; other assets default to 0 for existing accounts.
+func getAssetBalance(stub shim.ChaincodeStubInterface, name string, asset string) (int, error) {
+	value, err := getBalance(stub, name)
+	if err != nil || asset == defaultAsset {
+		return value, err
+	}
+
+	key, err := stub.CreateCompositeKey("balance", []string{asset, name})
+	if err != nil {
+		return 0, err
+	}
+	valbytes, err := stub.GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get %s state for %s", asset, name)
+	}
+	if valbytes == nil {
+		return 0, nil
+	}
+
+	value, err = strconv.Atoi(string(valbytes))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s balance for %s", asset, name)
+	}
+
+	return value, nil
+}
+
+func putAssetBalance(stub shim.ChaincodeStubInterface, name string, asset string, value int) error {
+	if asset == defaultAsset {
+		return stub.PutState(name, []byte(strconv.Itoa(value)))
+	}
+
+	key, err := stub.CreateCompositeKey("balance", []string{asset, name})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(key, []byte(strconv.Itoa(value)))
+}
+
+// issue credits newly issued units to an account as a fresh lot that expires
+// after the configured lot period
+func (t *SimpleChaincode) issue(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting name and amount")
+	}
+
+	A := args[0]
+
+	_, err := requireRole(stub, issuerRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	X, err := strconv.Atoi(args[1])
+	if err != nil {
+		return shim.Error("Invalid transaction amount, expecting a integer value")
+	}
+	if X < 1 {
+		return shim.Error("Invalid transaction amount, expecting a positive value")
+	}
+
+	Aval, err := getBalance(stub, A)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = addNewLot(stub, A, X)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	Aval = Aval + X
+	err = stub.PutState(A, []byte(strconv.Itoa(Aval)))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// expire removes lapsed lots, of one account or of every account, and takes
+// them off the account balances
+func (t *SimpleChaincode) expire(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting an optional name")
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("lot", args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+
+	expired := map[string]int{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		lot := Lot{}
+		err = json.Unmarshal(response.Value, &lot)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if !lotExpired(lot, now) {
+			continue
+		}
+
+		_, keys, err := stub.SplitCompositeKey(response.Key)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = stub.DelState(response.Key)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		expired[keys[0]] += lot.Amount
+	}
+
+	accounts := []string{}
+	for account := range expired {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	for _, account := range accounts {
+		value, err := getBalance(stub, account)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		value = value - expired[account]
+		fmt.Printf("%s = %d after expiring %d\n", account, value, expired[account])
+
+		err = stub.PutState(account, []byte(strconv.Itoa(value)))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	expiredBytes, err := json.Marshal(expired)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success([]byte(`{"expired":` + string(expiredBytes) + `}`))
+}
+
+// queryLots returns the dated lots making up an account's balance. Any part of
+// the balance not covered by lots is reported as untracked and never expires.
+func (t *SimpleChaincode) queryLots(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting name of the person to query")
+	}
+
+	A := args[0]
+
+	Aval, err := getBalance(stub, A)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lots, _, err := getLots(stub, A)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	breakdown := LotBreakdown{}
+	breakdown.Id = A
+	breakdown.Value = Aval
+	breakdown.Untracked = Aval - sumLots(lots)
+	breakdown.Lots = lots
+
+	breakdownBytes, err := json.Marshal(breakdown)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(breakdownBytes)
+}
+
+// setLotPeriod sets the number of seconds newly issued lots last, 0 to stop
+// issuing lots
+func (t *SimpleChaincode) setLotPeriod(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting lot period in seconds")
+	}
+
+	_, err := requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	period, err := strconv.Atoi(args[0])
+	if err != nil || period < 0 {
+		return shim.Error("Expecting a non-negative integer lot period")
+	}
+
+	err = putConfig(stub, "lotPeriod", strconv.Itoa(period))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// consumeLots takes amount off an account holding value, oldest first, and
+// returns the lots taken. The untracked part of the balance predates any lot
+// so it is spent before them. Lapsed lots the expire sweep has not removed yet
+// are never spent.
+func consumeLots(stub shim.ChaincodeStubInterface, name string, value int, amount int) ([]Lot, error) {
+	lots, keys, err := getLots(stub, name)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	lapsed := 0
+	for _, lot := range lots {
+		if lotExpired(lot, now) {
+			lapsed = lapsed + lot.Amount
+		}
+	}
+	if lapsed > 0 && amount > value-lapsed {
+		return nil, fmt.Errorf("Insufficient unexpired balance for %s, %d of %d has expired", name, lapsed, value)
+	}
+
+	untracked := value - sumLots(lots)
+	if untracked > 0 {
+		amount = amount - untracked
+	}
+
+	consumed := []Lot{}
+	for i := 0; i < len(lots) && amount > 0; i++ {
+		lot := lots[i]
+		if lotExpired(lot, now) {
+			continue
+		}
+
+		if lot.Amount <= amount {
+			err = stub.DelState(keys[i])
+		} else {
+			lots[i].Amount = lot.Amount - amount
+			lot.Amount = amount
+			err = putLot(stub, keys[i], lots[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		amount = amount - lot.Amount
+		consumed = append(consumed, lot)
+	}
+
+	return consumed, nil
+}
+
+// creditLots adds lots to an account, keeping their issue and expiry dates
+func creditLots(stub shim.ChaincodeStubInterface, name string, lots []Lot) error {
+	for _, lot := range lots {
+		key, err := getLotKey(stub, name, lot)
+		if err != nil {
+			return err
+		}
+
+		existing, err := stub.GetState(key)
+		if err != nil {
+			return fmt.Errorf("Failed to get lot %s for %s", lot.Id, name)
+		}
+		if existing != nil {
+			held := Lot{}
+			err = json.Unmarshal(existing, &held)
+			if err != nil {
+				return err
+			}
+			lot.Amount = lot.Amount + held.Amount
+		}
+
+		err = putLot(stub, key, lot)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addNewLot records amount as a lot issued now, if a lot period is configured
+func addNewLot(stub shim.ChaincodeStubInterface, name string, amount int) error {
+	value, err := getConfig(stub, "lotPeriod")
+	if err != nil {
+		return err
+	}
+	if value == "" || value == "0" {
+		return nil
+	}
+	period, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	lot := Lot{}
+	lot.Id = stub.GetTxID()
+	lot.Amount = amount
+	lot.Issued = now
+	lot.Expires = now + period
+
+	return creditLots(stub, name, []Lot{lot})
+}
+
+// getLots returns an account's lots and their keys, oldest first
+func getLots(stub shim.ChaincodeStubInterface, name string) ([]Lot, []string, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("lot", []string{name})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resultsIterator.Close()
+
+	lots := []Lot{}
+	keys := []string{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lot := Lot{}
+		err = json.Unmarshal(response.Value, &lot)
+		if err != nil {
+			return nil, nil, err
+		}
+		lots = append(lots, lot)
+		keys = append(keys, response.Key)
+	}
+
+	return lots, keys, nil
+}
+
+// deleteLots removes every lot of a deleted account
+func deleteLots(stub shim.ChaincodeStubInterface, name string) error {
+	_, keys, err := getLots(stub, name)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = stub.DelState(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getLotKey orders an account's lots by issue time so a partial key scan
+// returns them oldest first
+func getLotKey(stub shim.ChaincodeStubInterface, name string, lot Lot) (string, error) {
+	return stub.CreateCompositeKey("lot", []string{name, fmt.Sprintf("%020d", lot.Issued), lot.Id})
+}
+
+func putLot(stub shim.ChaincodeStubInterface, key string, lot Lot) error {
+	lotBytes, err := json.Marshal(lot)
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(key, lotBytes)
+}
+
+func lotExpired(lot Lot, now int64) bool {
+	return lot.Expires != 0 && lot.Expires <= now
+}
+
+func sumLots(lots []Lot) int {
+	total := 0
+	for _, lot := range lots {
+		total = total + lot.Amount
+	}
+
+	return total
+}
+
+// moveReversible debits A and holds X units pending at B until the dispute
+// window closes or an arbiter resolves a dispute
+func (t *SimpleChaincode) moveReversible(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var A, B string // Entities
+	var Aval int    // Asset holdings
+	var X int       // Transaction value
+	var err error
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+
+	A = args[0]
+	B = args[1]
+
+	Aval, err = getBalance(stub, A)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, err = getBalance(stub, B)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	X, err = strconv.Atoi(args[2])
+	if err != nil {
+		return shim.Error("Invalid transaction amount, expecting a integer value")
+	}
+	if X < 1 {
+		return shim.Error("Invalid transaction amount, expecting a positive value")
+	}
+
+	submitter, err := getCallerId(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	window, err := getDisputeWindow(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	payment := Payment{}
+	payment.Id = stub.GetTxID()
+	payment.Sender = A
+	payment.Recipient = B
+	payment.Amount = X
+	payment.Submitter = submitter
+	payment.Created = now
+	payment.DisputeDeadline = now + window
+	payment.Status = paymentPending
+
+	// The funds leave A now and only reach B once the payment is settled
+	payment.Lots, err = consumeLots(stub, A, Aval, X)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	Aval = Aval - X
+	fmt.Printf("Aval = %d, pending for %s = %d\n", Aval, B, X)
+
+	err = stub.PutState(A, []byte(strconv.Itoa(Aval)))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = putPayment(stub, payment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(paymentBytes)
+}
+
+// dispute lets the submitter of a reversible payment contest it before its
+// dispute window closes
+func (t *SimpleChaincode) dispute(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting payment id")
+	}
+
+	payment, err := getPayment(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if payment.Status != paymentPending {
+		return shim.Error("Payment " + payment.Id + " is " + payment.Status + ", only pending payments can be disputed")
+	}
+
+	caller, err := getCallerId(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if caller != payment.Submitter {
+		return shim.Error("Only the sender of payment " + payment.Id + " can dispute it")
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if now > payment.DisputeDeadline {
+		return shim.Error("The dispute window for payment " + payment.Id + " has closed")
+	}
+
+	payment.Status = paymentDisputed
+	err = putPayment(stub, payment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// resolve lets an arbiter settle a disputed payment to the recipient or
+// reverse it back to the sender
+func (t *SimpleChaincode) resolve(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting payment id and \"settle\" or \"reverse\"")
+	}
+
+	caller, err := requireRole(stub, arbiterRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	payment, err := getPayment(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if payment.Status != paymentDisputed {
+		return shim.Error("Payment " + payment.Id + " is " + payment.Status + ", only disputed payments can be resolved")
+	}
+
+	var account string
+	if args[1] == "settle" {
+		account = payment.Recipient
+		payment.Status = paymentSettled
+	} else if args[1] == "reverse" {
+		account = payment.Sender
+		payment.Status = paymentReversed
+	} else {
+		return shim.Error("Invalid resolution " + args[1] + ". Expecting \"settle\" or \"reverse\"")
+	}
+	payment.ResolvedBy = caller
+
+	err = closePayment(stub, payment, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// release settles an undisputed payment to the recipient once its dispute
+// window has closed
+func (t *SimpleChaincode) release(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting payment id")
+	}
+
+	payment, err := getPayment(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if payment.Status != paymentPending {
+		return shim.Error("Payment " + payment.Id + " is " + payment.Status + ", only pending payments can be released")
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if now <= payment.DisputeDeadline {
+		return shim.Error("Payment " + payment.Id + " can not be released before its dispute window closes")
+	}
+
+	payment.Status = paymentSettled
+	err = closePayment(stub, payment, payment.Recipient)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+func (t *SimpleChaincode) queryPayment(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting payment id")
+	}
+
+	payment, err := getPayment(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(paymentBytes)
+}
+
+// queryPending lists the payments still pending or disputed for a recipient
+func (t *SimpleChaincode) queryPending(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting name of the person to query")
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("pending", []string{args[0]})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+
+	payments := []Payment{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		payment, err := getPayment(stub, string(response.Value))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		payments = append(payments, payment)
+	}
+
+	paymentsBytes, err := json.Marshal(payments)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(paymentsBytes)
+}
+
+// setDisputeWindow sets the number of seconds new reversible payments can be disputed
+func (t *SimpleChaincode) setDisputeWindow(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting dispute window in seconds")
+	}
+
+	_, err := requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	window, err := strconv.Atoi(args[0])
+	if err != nil || window < 0 {
+		return shim.Error("Expecting a non-negative integer dispute window")
+	}
+
+	err = putConfig(stub, "disputeWindow", strconv.Itoa(window))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// assignRole grants a role such as "arbiter" to an identity
+func (t *SimpleChaincode) assignRole(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting identity and role")
+	}
+
+	_, err := requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = putRole(stub, args[1], args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// closePayment credits a settled or reversed payment to account
+func closePayment(stub shim.ChaincodeStubInterface, payment Payment, account string) error {
+	value, err := getBalance(stub, account)
+	if err != nil {
+		return err
+	}
+
+	value = value + payment.Amount
+	fmt.Printf("%s = %d\n", account, value)
+
+	err = stub.PutState(account, []byte(strconv.Itoa(value)))
+	if err != nil {
+		return err
+	}
+
+	err = creditLots(stub, account, payment.Lots)
+	if err != nil {
+		return err
+	}
+
+	return putPayment(stub, payment)
+}
+
+func getBalance(stub shim.ChaincodeStubInterface, name string) (int, error) {
+	valbytes, err := stub.GetState(name)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get state for %s", name)
+	}
+	if valbytes == nil {
+		return 0, fmt.Errorf("Entity not found: %s", name)
+	}
+
+	val, err := strconv.Atoi(string(valbytes))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid balance for %s", name)
+	}
+
+	return val, nil
+}
+
+func getPayment(stub shim.ChaincodeStubInterface, id string) (Payment, error) {
+	payment := Payment{}
+
+	key, err := stub.CreateCompositeKey("payment", []string{id})
+	if err != nil {
+		return payment, err
+	}
+
+	paymentBytes, err := stub.GetState(key)
+	if err != nil {
+		return payment, fmt.Errorf("Failed to get payment %s", id)
+	}
+	if paymentBytes == nil {
+		return payment, fmt.Errorf("Payment not found: %s", id)
+	}
+
+	err = json.Unmarshal(paymentBytes, &payment)
+	return payment, err
+}
+
+// putPayment stores the payment and keeps the recipient's pending index in step
+func putPayment(stub shim.ChaincodeStubInterface, payment Payment) error {
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return err
+	}
+
+	key, err := stub.CreateCompositeKey("payment", []string{payment.Id})
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(key, paymentBytes)
+	if err != nil {
+		return err
+	}
+
+	pendingKey, err := stub.CreateCompositeKey("pending", []string{payment.Recipient, payment.Id})
+	if err != nil {
+		return err
+	}
+	if payment.Status == paymentPending || payment.Status == paymentDisputed {
+		return stub.PutState(pendingKey, []byte(payment.Id))
+	}
+
+	return stub.DelState(pendingKey)
+}
+
+func getDisputeWindow(stub shim.ChaincodeStubInterface) (int64, error) {
+	value, err := getConfig(stub, "disputeWindow")
+	if err != nil {
+		return 0, err
+	}
+	if value == "" {
+		return defaultDisputeWindow, nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func getConfig(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	key, err := stub.CreateCompositeKey("config", []string{name})
+	if err != nil {
+		return "", err
+	}
+
+	value, err := stub.GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get config %s", name)
+	}
+
+	return string(value), nil
+}
+
+func putConfig(stub shim.ChaincodeStubInterface, name string, value string) error {
+	key, err := stub.CreateCompositeKey("config", []string{name})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(key, []byte(value))
+}
+
+func putRole(stub shim.ChaincodeStubInterface, role string, identity string) error {
+	key, err := stub.CreateCompositeKey("role", []string{role, identity})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(key, []byte(identity))
+}
+
+// requireRole returns the caller's identity if it has been granted role
+func requireRole(stub shim.ChaincodeStubInterface, role string) (string, error) {
+	caller, err := getCallerId(stub)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := stub.CreateCompositeKey("role", []string{role, caller})
+	if err != nil {
+		return "", err
+	}
+
+	value, err := stub.GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get role %s", role)
+	}
+	if value == nil {
+		return "", fmt.Errorf("%s does not have the %s role", caller, role)
+	}
+
+	return caller, nil
+}
+
+// getCallerId identifies the submitter as <MSP ID>::<certificate common name>.
+// It is a variable so the tests can stand in for the creator the MockStub lacks.
+var getCallerId = func(stub shim.ChaincodeStubInterface) (string, error) {
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get caller MSP ID: %s", err)
+	}
+
+	cert, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get caller certificate: %s", err)
+	}
+	if cert == nil {
+		return "", fmt.Errorf("Caller has no X.509 certificate")
+	}
+
+	return mspId + "::" + cert.Subject.CommonName, nil
+}
+
+// getTxTime returns the transaction timestamp in unix seconds
+func getTxTime(stub shim.ChaincodeStubInterface) (int64, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return 0, err
+	}
+	if timestamp == nil {
+		return 0, fmt.Errorf("Transaction timestamp is not available")
+	}
+
+	return timestamp.Seconds, nil
+}
+
 func getAllFullByteArrays(aValBytes []byte, bValBytes []byte)([]byte, error){
 	aResult, err := getFullByteArray("a", aValBytes)
 	bResult, err := getFullByteArray("b", bValBytes)
@@ -0,0 +1,540 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chaincode_fileshare/basic/sumtree"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const (
+	admin   = "Org1MSP::admin"
+	arbiter = "Org1MSP::arbiter"
+	sender  = "Org1MSP::sender"
+	other   = "Org1MSP::other"
+)
+
+func TestMoveReversibleRelease(t *testing.T) {
+
+	stub := getStub(t)
+
+	setCaller(sender)
+	checkInvoke(t, stub, "payment_1", "moveReversible", "a", "b", "30")
+	checkBalance(t, stub, "a", 70)
+	checkBalance(t, stub, "b", 200)
+
+	pending := []Payment{}
+	json.Unmarshal(checkInvoke(t, stub, "query_1", "queryPending", "b"), &pending)
+	if len(pending) != 1 || pending[0].Id != "payment_1" || pending[0].Status != paymentPending {
+		fmt.Println("Unexpected pending payments:", pending)
+		t.FailNow()
+	}
+
+	checkInvokeFails(t, stub, "before its dispute window closes", "release_1", "release", "payment_1")
+
+	backdatePayment(stub, "payment_1", defaultDisputeWindow+1)
+	checkInvoke(t, stub, "release_2", "release", "payment_1")
+	checkBalance(t, stub, "b", 230)
+	checkPaymentStatus(t, stub, "payment_1", paymentSettled)
+
+	json.Unmarshal(checkInvoke(t, stub, "query_2", "queryPending", "b"), &pending)
+	if len(pending) != 0 {
+		fmt.Println("Released payment is still pending:", pending)
+		t.FailNow()
+	}
+
+}
+
+func TestDisputeResolvedByArbiter(t *testing.T) {
+
+	stub := getStub(t)
+	assignTestRole(t, stub, arbiter, arbiterRole)
+
+	setCaller(sender)
+	checkInvoke(t, stub, "payment_1", "moveReversible", "a", "b", "30")
+	checkInvoke(t, stub, "payment_2", "moveReversible", "a", "b", "20")
+
+	setCaller(other)
+	checkInvokeFails(t, stub, "Only the sender", "dispute_1", "dispute", "payment_1")
+
+	setCaller(sender)
+	checkInvoke(t, stub, "dispute_2", "dispute", "payment_1")
+	checkInvoke(t, stub, "dispute_3", "dispute", "payment_2")
+	checkPaymentStatus(t, stub, "payment_1", paymentDisputed)
+
+	// a disputed payment waits for the arbiter even after the window closes
+	backdatePayment(stub, "payment_1", defaultDisputeWindow+1)
+	checkInvokeFails(t, stub, "only pending payments can be released", "release_1", "release", "payment_1")
+	checkInvokeFails(t, stub, "does not have the arbiter role", "resolve_1", "resolve", "payment_1", "reverse")
+
+	setCaller(arbiter)
+	checkInvokeFails(t, stub, "Invalid resolution", "resolve_2", "resolve", "payment_1", "refund")
+	checkInvoke(t, stub, "resolve_3", "resolve", "payment_1", "reverse")
+	checkInvoke(t, stub, "resolve_4", "resolve", "payment_2", "settle")
+	checkInvokeFails(t, stub, "only disputed payments can be resolved", "resolve_5", "resolve", "payment_2", "reverse")
+
+	checkBalance(t, stub, "a", 80)
+	checkBalance(t, stub, "b", 220)
+
+	payment := checkPaymentStatus(t, stub, "payment_1", paymentReversed)
+	if payment.ResolvedBy != arbiter {
+		fmt.Println("Unexpected arbiter on resolved payment:", payment.ResolvedBy)
+		t.FailNow()
+	}
+
+}
+
+func TestDisputeAfterWindow(t *testing.T) {
+
+	stub := getStub(t)
+
+	setCaller(sender)
+	checkInvoke(t, stub, "payment_1", "moveReversible", "a", "b", "30")
+
+	backdatePayment(stub, "payment_1", defaultDisputeWindow+1)
+	checkInvokeFails(t, stub, "dispute window for payment payment_1 has closed", "dispute_1", "dispute", "payment_1")
+
+}
+
+func TestQueryAccountsPaging(t *testing.T) {
+
+	stub := getStub(t)
+	addTestAccount(stub, "c", 50)
+
+	page := checkQueryAccounts(t, stub, `{"limit":2}`, "a=100,b=200", "b")
+	page = checkQueryAccounts(t, stub, `{"limit":2,"bookmark":"`+page.Bookmark+`"}`, "c=50", "")
+
+	page = checkQueryAccounts(t, stub, `{"sort":"balanceDesc","limit":2}`, "b=200,a=100", "100:a")
+	checkQueryAccounts(t, stub, `{"sort":"balanceDesc","limit":2,"bookmark":"`+page.Bookmark+`"}`, "c=50", "")
+
+	checkQueryAccounts(t, stub, `{"sort":"balanceAsc","minBalance":60}`, "a=100,b=200", "")
+
+}
+
+func TestQueryAccountsOverdrawn(t *testing.T) {
+
+	stub := getStub(t)
+
+	checkInvoke(t, stub, "move_1", "move", "a", "b", "150")
+	checkQueryAccounts(t, stub, `{"status":"overdrawn"}`, "a=-50", "")
+
+}
+
+func TestQueryAccountsInvalidFilter(t *testing.T) {
+
+	stub := getStub(t)
+
+	checkInvokeFails(t, stub, "Limit must be between 1 and 1000, or 0 for the default of 100", "query_1", "queryAccounts", `{"limit":1001}`)
+	checkInvokeFails(t, stub, "Limit must be between 1 and 1000", "query_2", "queryAccounts", `{"limit":-1}`)
+	checkInvokeFails(t, stub, "Invalid sort", "query_3", "queryAccounts", `{"sort":"name"}`)
+	checkInvokeFails(t, stub, "Invalid bookmark", "query_4", "queryAccounts", `{"sort":"balanceAsc","bookmark":"a"}`)
+
+}
+
+func TestBalanceAt(t *testing.T) {
+
+	stub := getStub(t)
+
+	checkInvoke(t, stub, "checkpoint_1", "checkpoint", "q1")
+	checkInvoke(t, stub, "move_1", "move", "a", "b", "10")
+	checkInvoke(t, stub, "checkpoint_2", "checkpoint", "q2")
+
+	checkBalanceAt(t, stub, "q1", "a", 100)
+	checkBalanceAt(t, stub, "q1", "b", 200)
+	checkBalanceAt(t, stub, "q2", "a", 90)
+	checkBalance(t, stub, "a", 90)
+
+	checkpoint := Checkpoint{}
+	json.Unmarshal(checkInvoke(t, stub, "query_1", "queryCheckpoint", "q1"), &checkpoint)
+	if checkpoint.TxId != "checkpoint_1" || checkpoint.Accounts != 2 {
+		fmt.Println("Unexpected checkpoint:", checkpoint)
+		t.FailNow()
+	}
+
+	// accounts created after a checkpoint have no balance in it
+	addTestAccount(stub, "c", 50)
+	checkInvokeFails(t, stub, "No balance for c at checkpoint q1", "balanceAt_1", "balanceAt", "q1", "c")
+	checkInvokeFails(t, stub, "Checkpoint not found: q3", "balanceAt_2", "balanceAt", "q3", "a")
+
+}
+
+func TestCheckpointRules(t *testing.T) {
+
+	stub := getStub(t)
+
+	checkInvoke(t, stub, "checkpoint_1", "checkpoint", "q1")
+	checkInvokeFails(t, stub, "Checkpoint q1 already exists", "checkpoint_2", "checkpoint", "q1")
+	checkInvokeFails(t, stub, "A checkpoint label is required", "checkpoint_3", "checkpoint", " ")
+
+	setCaller(other)
+	checkInvokeFails(t, stub, "does not have the admin role", "checkpoint_4", "checkpoint", "q2")
+
+}
+
+func TestPublishReservesWithOverdraftAndEscrow(t *testing.T) {
+
+	stub := getStub(t)
+
+	setCaller(sender)
+	checkInvoke(t, stub, "payment_1", "moveReversible", "a", "b", "30")
+	checkInvoke(t, stub, "move_1", "move", "a", "b", "120")
+	checkBalance(t, stub, "a", -50)
+
+	setCaller(admin)
+	reserves := Reserves{}
+	json.Unmarshal(checkInvoke(t, stub, "reserves_1", "publishReserves"), &reserves)
+	if reserves.Total != 350 || reserves.Escrow != 30 || reserves.Overdrawn != 50 || reserves.Accounts != 2 {
+		fmt.Println("Unexpected reserves:", reserves)
+		t.FailNow()
+	}
+
+	checkReservesProof(t, stub, "a", 0, reserves)
+	checkReservesProof(t, stub, "b", 320, reserves)
+	checkReservesProof(t, stub, "escrow:payment_1", 30, reserves)
+
+	checkInvokeFails(t, stub, "No balance for c", "prove_1", "proveReserves", "c")
+
+}
+
+func TestPublishReservesNotAdmin(t *testing.T) {
+
+	stub := getStub(t)
+
+	setCaller(other)
+	checkInvokeFails(t, stub, "does not have the admin role", "reserves_1", "publishReserves")
+	checkInvokeFails(t, stub, "No reserves have been published", "query_1", "queryReserves")
+
+}
+
+func TestConvertAmountRounding(t *testing.T) {
+
+	conversions := []struct {
+		amount   int
+		rate     Rate
+		expected int
+	}{
+		{100, Rate{Rate: "0.92", SpreadBps: 25, Rounding: roundDown}, 91},
+		{100, Rate{Rate: "0.92", SpreadBps: 25, Rounding: roundUp}, 92},
+		{100, Rate{Rate: "0.92", SpreadBps: 25, Rounding: roundHalfEven}, 92},
+		{100, Rate{Rate: "0.92", SpreadBps: 0, Rounding: roundUp}, 92},
+		{5, Rate{Rate: "0.5", Rounding: roundHalfEven}, 2},
+		{3, Rate{Rate: "0.5", Rounding: roundHalfEven}, 2},
+		{3, Rate{Rate: "0.5", Rounding: roundDown}, 1},
+		{1000, Rate{Rate: "1", SpreadBps: 9999, Rounding: roundDown}, 0},
+	}
+
+	for _, conversion := range conversions {
+
+		converted, err := convertAmount(conversion.amount, conversion.rate)
+		if err != nil || converted != conversion.expected {
+			fmt.Println("Converting", conversion.amount, "at", conversion.rate, "gave", converted, err, "expected", conversion.expected)
+			t.FailNow()
+		}
+
+	}
+
+}
+
+func TestExchange(t *testing.T) {
+
+	stub := getStub(t)
+
+	setCaller(other)
+	checkInvokeFails(t, stub, "does not have the rateSetter role", "rate_1", "setRate", `{"from":"default","to":"EUR","rate":"0.92"}`)
+
+	assignTestRole(t, stub, other, rateSetterRole)
+	setCaller(other)
+	checkInvokeFails(t, stub, "Spread must be between 0 and 9999", "rate_2", "setRate", `{"from":"default","to":"EUR","rate":"0.92","spreadBps":10000}`)
+	checkInvoke(t, stub, "rate_3", "setRate", `{"from":"default","to":"EUR","rate":"0.92","spreadBps":25}`)
+	checkInvoke(t, stub, "rate_4", "setRate", `{"from":"EUR","to":"default","rate":"1.08","rounding":"halfEven"}`)
+
+	exchange := Exchange{}
+	json.Unmarshal(checkInvoke(t, stub, "exchange_1", "exchange", "a", "default", "EUR", "100"), &exchange)
+	if exchange.Debited != 100 || exchange.Credited != 91 || exchange.Rounding != roundDown {
+		fmt.Println("Unexpected exchange:", exchange)
+		t.FailNow()
+	}
+	checkBalance(t, stub, "a", 0)
+
+	checkInvokeFails(t, stub, "is too small to exchange", "exchange_2", "exchange", "b", "default", "EUR", "1")
+	checkInvokeFails(t, stub, "Insufficient EUR balance for a", "exchange_3", "exchange", "a", "EUR", "default", "92")
+
+	// 45 EUR at 1.08 is 48.6, rounded half to even
+	checkInvoke(t, stub, "exchange_4", "exchange", "a", "EUR", "default", "45")
+	checkBalance(t, stub, "a", 49)
+
+	balances := struct {
+		Balances map[string]int `json:"balances"`
+	}{}
+	json.Unmarshal(checkInvoke(t, stub, "query_1", "queryBalances", "a"), &balances)
+	if balances.Balances["EUR"] != 46 || balances.Balances[defaultAsset] != 49 {
+		fmt.Println("Unexpected balances:", balances)
+		t.FailNow()
+	}
+
+}
+
+func TestMoveSkipsExpiredLots(t *testing.T) {
+
+	stub := getStub(t)
+	checkInvoke(t, stub, "period_1", "setLotPeriod", "3600")
+	assignTestRole(t, stub, admin, issuerRole)
+
+	checkInvoke(t, stub, "issue_1", "issue", "a", "50")
+	expireLots(stub, "a")
+	checkBalance(t, stub, "a", 150)
+
+	checkInvokeFails(t, stub, "Insufficient unexpired balance for a, 50 of 150 has expired", "move_1", "move", "a", "b", "120")
+	checkInvokeFails(t, stub, "Insufficient unexpired balance for a", "payment_1", "moveReversible", "a", "b", "120")
+
+	checkInvoke(t, stub, "move_2", "move", "a", "b", "80")
+	checkLots(t, stub, "b", "")
+
+	// the untracked 20 goes first, then the fresh lot, skipping the lapsed one
+	checkInvoke(t, stub, "issue_2", "issue", "a", "30")
+	checkInvoke(t, stub, "move_3", "move", "a", "b", "40")
+	checkLots(t, stub, "b", "issue_2=20")
+	checkLots(t, stub, "a", "issue_1=50,issue_2=10")
+
+	checkInvoke(t, stub, "expire_1", "expire")
+	checkBalance(t, stub, "a", 10)
+	checkBalance(t, stub, "b", 320)
+	checkLots(t, stub, "a", "issue_2=10")
+
+}
+
+func TestIssueRequiresIssuer(t *testing.T) {
+
+	stub := getStub(t)
+
+	setCaller(other)
+	checkInvokeFails(t, stub, "does not have the issuer role", "issue_1", "issue", "a", "50")
+
+}
+
+//====================================================
+// helper methods
+//====================================================
+
+// setCaller makes every following invocation come from identity
+func setCaller(identity string) {
+
+	getCallerId = func(stub shim.ChaincodeStubInterface) (string, error) {
+		return identity, nil
+	}
+
+}
+
+// getStub instantiates the chaincode as admin with a = 100 and b = 200
+func getStub(t *testing.T) *shim.MockStub {
+
+	stub := shim.NewMockStub("step1", new(SimpleChaincode))
+
+	setCaller(admin)
+	res := stub.MockInit("init", getArgs("init", "a", "100", "b", "200"))
+	if res.Status != shim.OK {
+		fmt.Println("Init failed:", res.Message)
+		t.FailNow()
+	}
+
+	return stub
+
+}
+
+func getArgs(args ...string) [][]byte {
+
+	byteArgs := [][]byte{}
+	for _, arg := range args {
+		byteArgs = append(byteArgs, []byte(arg))
+	}
+
+	return byteArgs
+
+}
+
+// checkInvoke runs args as transaction txId and returns its payload
+func checkInvoke(t *testing.T, stub *shim.MockStub, txId string, args ...string) []byte {
+
+	res := stub.MockInvoke(txId, getArgs(args...))
+	if res.Status != shim.OK {
+		fmt.Println(args[0], "failed:", res.Message)
+		t.FailNow()
+	}
+
+	return res.Payload
+
+}
+
+// checkInvokeFails runs args as transaction txId and expects an error containing errorMessage
+func checkInvokeFails(t *testing.T, stub *shim.MockStub, errorMessage string, txId string, args ...string) {
+
+	res := stub.MockInvoke(txId, getArgs(args...))
+	if res.Status == shim.OK {
+		fmt.Println(args[0], "with args", args[1:], "did not fail")
+		t.FailNow()
+	}
+	if !strings.Contains(res.Message, errorMessage) {
+		fmt.Println(args[0], "failed with", res.Message, "expected", errorMessage)
+		t.FailNow()
+	}
+
+}
+
+func checkBalance(t *testing.T, stub *shim.MockStub, name string, value int) {
+
+	balance, _ := strconv.Atoi(string(stub.State[name]))
+	if stub.State[name] == nil || balance != value {
+		fmt.Println("Balance of", name, "is", string(stub.State[name]), "expected", value)
+		t.FailNow()
+	}
+
+}
+
+func checkPaymentStatus(t *testing.T, stub *shim.MockStub, id string, status string) Payment {
+
+	payment := Payment{}
+	json.Unmarshal(checkInvoke(t, stub, "queryPayment", "queryPayment", id), &payment)
+	if payment.Status != status {
+		fmt.Println("Payment", id, "is", payment.Status, "expected", status)
+		t.FailNow()
+	}
+
+	return payment
+
+}
+
+// assignTestRole grants role to identity as admin
+func assignTestRole(t *testing.T, stub *shim.MockStub, identity string, role string) {
+
+	setCaller(admin)
+	checkInvoke(t, stub, "assignRole", "assignRole", identity, role)
+
+}
+
+// addTestAccount creates an account directly in state
+func addTestAccount(stub *shim.MockStub, name string, value int) {
+
+	stub.MockTransactionStart("addTestAccount")
+	stub.PutState(name, []byte(strconv.Itoa(value)))
+	stub.MockTransactionEnd("addTestAccount")
+
+}
+
+// checkQueryAccounts runs queryAccounts with filter and compares the page,
+// written as id=value pairs, and its bookmark
+func checkQueryAccounts(t *testing.T, stub *shim.MockStub, filter string, accounts string, bookmark string) AccountPage {
+
+	page := AccountPage{}
+	json.Unmarshal(checkInvoke(t, stub, "queryAccounts", "queryAccounts", filter), &page)
+
+	values := []string{}
+	for _, account := range page.Accounts {
+		values = append(values, account.Id+"="+strconv.Itoa(account.Value))
+	}
+	if strings.Join(values, ",") != accounts || page.Bookmark != bookmark {
+		fmt.Println("queryAccounts", filter, "returned", values, "bookmark", page.Bookmark)
+		t.FailNow()
+	}
+
+	return page
+
+}
+
+func checkBalanceAt(t *testing.T, stub *shim.MockStub, label string, name string, value int) {
+
+	balance := struct {
+		Value int `json:"value"`
+	}{}
+	json.Unmarshal(checkInvoke(t, stub, "balanceAt", "balanceAt", label, name), &balance)
+	if balance.Value != value {
+		fmt.Println("Balance of", name, "at", label, "is", balance.Value, "expected", value)
+		t.FailNow()
+	}
+
+}
+
+// checkReservesProof proves name's leaf in the latest reserves and verifies it
+// the way an account holder would off-chain
+func checkReservesProof(t *testing.T, stub *shim.MockStub, name string, value int64, reserves Reserves) {
+
+	proof := sumtree.Proof{}
+	json.Unmarshal(checkInvoke(t, stub, "proveReserves", "proveReserves", name), &proof)
+	if proof.Value != value || proof.RootHash != reserves.RootHash || proof.Total != reserves.Total {
+		fmt.Println("Unexpected proof for", name, ":", proof)
+		t.FailNow()
+	}
+
+	err := sumtree.Verify(proof, reserves.RootHash, reserves.Total)
+	if err != nil {
+		fmt.Println("Proof for", name, "does not verify:", err)
+		t.FailNow()
+	}
+
+}
+
+// checkLots compares an account's lots, written as id=amount pairs
+func checkLots(t *testing.T, stub *shim.MockStub, name string, expected string) {
+
+	breakdown := LotBreakdown{}
+	json.Unmarshal(checkInvoke(t, stub, "queryLots", "queryLots", name), &breakdown)
+
+	lots := []string{}
+	for _, lot := range breakdown.Lots {
+		lots = append(lots, lot.Id+"="+strconv.Itoa(lot.Amount))
+	}
+	if strings.Join(lots, ",") != expected {
+		fmt.Println("Lots of", name, "are", lots, "expected", expected)
+		t.FailNow()
+	}
+
+}
+
+// expireLots makes every lot an account holds lapse without sweeping it
+func expireLots(stub *shim.MockStub, name string) {
+
+	stub.MockTransactionStart("expireLots")
+
+	lots, keys, _ := getLots(stub, name)
+	for i, lot := range lots {
+		lot.Expires = 1
+		putLot(stub, keys[i], lot)
+	}
+
+	stub.MockTransactionEnd("expireLots")
+
+}
+
+// backdatePayment moves a payment's creation and dispute deadline seconds into
+// the past, since the MockStub always stamps transactions with the current time
+func backdatePayment(stub *shim.MockStub, id string, seconds int64) {
+
+	stub.MockTransactionStart("backdatePayment")
+
+	payment, _ := getPayment(stub, id)
+	payment.Created = payment.Created - seconds
+	payment.DisputeDeadline = payment.DisputeDeadline - seconds
+	putPayment(stub, payment)
+
+	stub.MockTransactionEnd("backdatePayment")
+
+}
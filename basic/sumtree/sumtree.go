@@ -0,0 +1,245 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sumtree builds Merkle sum trees over account balances and verifies
+// inclusion proofs against a published root. The chaincode uses it to build
+// roots and proofs; account holders can use Verify off-chain, passing the root
+// hash and total they got from the published reserves rather than trusting the
+// ones carried in the proof.
+//
+// Every node commits to its children's hashes and sums, so a proof that
+// verifies against the published root shows the account's balance is counted
+// in the published total.
+package sumtree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Leaf is one account balance in the tree
+type Leaf struct {
+	Id    string `json:"id"`
+	Value int64  `json:"value"`
+}
+
+// Node is a hash and the sum of the balances beneath it
+type Node struct {
+	Hash []byte
+	Sum  int64
+}
+
+// Step is the sibling needed to hash one level up. Left reports whether the
+// sibling sits to the left of the path being proven.
+type Step struct {
+	Hash string `json:"hash"`
+	Sum  int64  `json:"sum"`
+	Left bool   `json:"left"`
+}
+
+// Proof shows that Id with Value is included in the tree with RootHash and Total
+type Proof struct {
+	Nonce    string `json:"nonce"`
+	Id       string `json:"id"`
+	Value    int64  `json:"value"`
+	Steps    []Step `json:"steps"`
+	RootHash string `json:"rootHash"`
+	Total    int64  `json:"total"`
+}
+
+// LeafHash commits to an account and its balance. The nonce keeps leaf hashes
+// from one tree from being linked to another.
+func LeafHash(nonce string, id string, value int64) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteByte(0)
+	writeString(&buffer, nonce)
+	writeString(&buffer, id)
+	binary.Write(&buffer, binary.BigEndian, value)
+
+	hash := sha256.Sum256(buffer.Bytes())
+	return hash[:]
+}
+
+// NodeHash commits to two children and their sums
+func NodeHash(left Node, right Node) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteByte(1)
+	buffer.Write(left.Hash)
+	binary.Write(&buffer, binary.BigEndian, left.Sum)
+	buffer.Write(right.Hash)
+	binary.Write(&buffer, binary.BigEndian, right.Sum)
+
+	hash := sha256.Sum256(buffer.Bytes())
+	return hash[:]
+}
+
+// Root builds the tree over leaves in the order given and returns its root
+func Root(nonce string, leaves []Leaf) (Node, error) {
+	level, err := leafNodes(nonce, leaves)
+	if err != nil {
+		return Node{}, err
+	}
+
+	for len(level) > 1 {
+		level, err = parentLevel(level)
+		if err != nil {
+			return Node{}, err
+		}
+	}
+
+	return level[0], nil
+}
+
+// Prove builds the inclusion proof for the leaf at index
+func Prove(nonce string, leaves []Leaf, index int) (Proof, error) {
+	proof := Proof{}
+
+	if index < 0 || index >= len(leaves) {
+		return proof, fmt.Errorf("Leaf index %d out of range", index)
+	}
+
+	level, err := leafNodes(nonce, leaves)
+	if err != nil {
+		return proof, err
+	}
+
+	proof.Nonce = nonce
+	proof.Id = leaves[index].Id
+	proof.Value = leaves[index].Value
+	proof.Steps = []Step{}
+
+	for len(level) > 1 {
+		// an unpaired last node is carried up unchanged and needs no step
+		if index%2 == 1 {
+			proof.Steps = append(proof.Steps, step(level[index-1], true))
+		} else if index+1 < len(level) {
+			proof.Steps = append(proof.Steps, step(level[index+1], false))
+		}
+
+		level, err = parentLevel(level)
+		if err != nil {
+			return proof, err
+		}
+		index = index / 2
+	}
+
+	proof.RootHash = hex.EncodeToString(level[0].Hash)
+	proof.Total = level[0].Sum
+
+	return proof, nil
+}
+
+// Verify recomputes the root from the proof and checks it against the
+// published root hash and total. A proof is only as good as the root it is
+// checked against, since anyone can build a consistent proof for a tree of
+// their own.
+func Verify(proof Proof, publishedRoot string, publishedTotal int64) error {
+	if proof.RootHash != publishedRoot || proof.Total != publishedTotal {
+		return errors.New("Proof is not for the published root")
+	}
+	if proof.Value < 0 {
+		return errors.New("Proven value is negative")
+	}
+
+	node := Node{LeafHash(proof.Nonce, proof.Id, proof.Value), proof.Value}
+
+	for i, step := range proof.Steps {
+		if step.Sum < 0 {
+			return fmt.Errorf("Step %d has a negative sum", i)
+		}
+
+		siblingHash, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return fmt.Errorf("Step %d has an invalid hash: %s", i, err)
+		}
+		sibling := Node{siblingHash, step.Sum}
+
+		if step.Left {
+			node, err = parent(sibling, node)
+		} else {
+			node, err = parent(node, sibling)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if hex.EncodeToString(node.Hash) != proof.RootHash {
+		return errors.New("Proof does not lead to the root hash")
+	}
+	if node.Sum != proof.Total {
+		return fmt.Errorf("Proof sums to %d, expected total %d", node.Sum, proof.Total)
+	}
+
+	return nil
+}
+
+func leafNodes(nonce string, leaves []Leaf) ([]Node, error) {
+	if len(leaves) == 0 {
+		return nil, errors.New("A sum tree needs at least one leaf")
+	}
+
+	nodes := make([]Node, len(leaves))
+	for i, leaf := range leaves {
+		if leaf.Value < 0 {
+			return nil, fmt.Errorf("Leaf %s has a negative value", leaf.Id)
+		}
+		nodes[i] = Node{LeafHash(nonce, leaf.Id, leaf.Value), leaf.Value}
+	}
+
+	return nodes, nil
+}
+
+func parentLevel(level []Node) ([]Node, error) {
+	parents := make([]Node, 0, (len(level)+1)/2)
+
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			parents = append(parents, level[i])
+			break
+		}
+
+		node, err := parent(level[i], level[i+1])
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, node)
+	}
+
+	return parents, nil
+}
+
+func parent(left Node, right Node) (Node, error) {
+	if left.Sum > math.MaxInt64-right.Sum {
+		return Node{}, errors.New("Sum overflows")
+	}
+
+	return Node{NodeHash(left, right), left.Sum + right.Sum}, nil
+}
+
+func step(sibling Node, left bool) Step {
+	return Step{hex.EncodeToString(sibling.Hash), sibling.Sum, left}
+}
+
+func writeString(buffer *bytes.Buffer, value string) {
+	binary.Write(buffer, binary.BigEndian, uint32(len(value)))
+	buffer.WriteString(value)
+}
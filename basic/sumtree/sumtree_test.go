@@ -0,0 +1,138 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sumtree
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+const nonce = "tx1"
+
+func TestProveAndVerifyEveryLeaf(t *testing.T) {
+
+	for count := 1; count <= 7; count++ {
+
+		leaves := getLeaves(count)
+
+		root, err := Root(nonce, leaves)
+		if err != nil {
+			fmt.Println("Root with", count, "leaves failed:", err)
+			t.FailNow()
+		}
+
+		for i := 0; i < count; i++ {
+
+			proof, err := Prove(nonce, leaves, i)
+			if err != nil {
+				fmt.Println("Prove leaf", i, "of", count, "failed:", err)
+				t.FailNow()
+			}
+			if proof.Total != root.Sum {
+				fmt.Println("Proof total", proof.Total, "was not", root.Sum)
+				t.FailNow()
+			}
+
+			err = Verify(proof, hex.EncodeToString(root.Hash), root.Sum)
+			if err != nil {
+				fmt.Println("Verify leaf", i, "of", count, "failed:", err)
+				t.FailNow()
+			}
+
+		}
+
+	}
+
+}
+
+func TestVerifyTamperedProof(t *testing.T) {
+
+	leaves := getLeaves(5)
+	root, _ := Root(nonce, leaves)
+	rootHash := hex.EncodeToString(root.Hash)
+
+	proof, _ := Prove(nonce, leaves, 2)
+	proof.Value = proof.Value + 1
+	if Verify(proof, rootHash, root.Sum) == nil {
+		fmt.Println("Proof with a changed value verified")
+		t.FailNow()
+	}
+
+	proof, _ = Prove(nonce, leaves, 2)
+	proof.Steps[0].Sum = -proof.Steps[0].Sum
+	if Verify(proof, rootHash, root.Sum) == nil {
+		fmt.Println("Proof with a negative sibling sum verified")
+		t.FailNow()
+	}
+
+	proof, _ = Prove(nonce, leaves, 2)
+	proof.Total = proof.Total - 1
+	if Verify(proof, rootHash, root.Sum) == nil {
+		fmt.Println("Proof with a changed total verified")
+		t.FailNow()
+	}
+
+}
+
+func TestVerifyForgedProof(t *testing.T) {
+
+	leaves := getLeaves(5)
+	root, _ := Root(nonce, leaves)
+
+	// a tree that includes the same leaf but hides the other balances is
+	// consistent with itself but not with the published root
+	forged := []Leaf{leaves[2], {Id: "hidden", Value: 0}}
+	proof, _ := Prove(nonce, forged, 0)
+
+	forgedRoot, _ := Root(nonce, forged)
+	if Verify(proof, hex.EncodeToString(forgedRoot.Hash), forgedRoot.Sum) != nil {
+		fmt.Println("Forged proof is not consistent with its own root")
+		t.FailNow()
+	}
+
+	if Verify(proof, hex.EncodeToString(root.Hash), root.Sum) == nil {
+		fmt.Println("Forged proof verified against the published root")
+		t.FailNow()
+	}
+
+}
+
+func TestRootNegativeValue(t *testing.T) {
+
+	leaves := getLeaves(3)
+	leaves[1].Value = -1
+
+	_, err := Root(nonce, leaves)
+	if err == nil {
+		fmt.Println("Root with a negative leaf did not fail")
+		t.FailNow()
+	}
+
+}
+
+func getLeaves(count int) []Leaf {
+
+	leaves := []Leaf{}
+	for i := 0; i < count; i++ {
+		leaves = append(leaves, Leaf{"account" + strconv.Itoa(i), int64(10 * (i + 1))})
+	}
+
+	return leaves
+
+}
@@ -21,16 +21,98 @@ import (
 	"bytes"
 	"errors"
 	"strings"
+	"math"
+	"math/big"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
 	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
 	"strconv"
+	"time"
 )
 
+const adminRole = "admin"
+const registrarRole = "registrar"
+const executorRole = "executor"
+const assessorRole = "assessor"
+const complianceRole = "compliance"
+
+const transferSale = "sale"
+const transferGift = "gift"
+const transferInheritance = "inheritance"
+const transferForeclosure = "foreclosure"
+const transferCourtOrder = "court-order"
+const transferCorporateRestructuring = "corporate-restructuring"
+
+//share of the current owners that must accept an offer unless configured otherwise
+const defaultAcceptanceThreshold = "1"
+
+//days a tax bill may stay unpaid past its due date before the property is delinquent
+const defaultTaxDelinquencyDays = "365"
+const taxLienHolder = "property tax"
+
+//lien ids starting with this are reserved for the liens recordTaxLiens records
+const taxLienPrefix = "tax-"
+
+const offerOpen = "open"
+const offerAccepted = "accepted"
+const offerClosed = "closed"
+
+const statusOffMarket = "off-market"
+const statusListed = "listed"
+const statusUnderContract = "under-contract"
+const statusSold = "sold"
+const statusWithdrawn = "withdrawn"
+
+//a property replaced by a split or merge is retired and can not move to any other status
+const statusRetired = "retired"
+
+//propertyStatusTransitions lists the statuses each listing status can move to
+var propertyStatusTransitions = map[string][]string{
+	statusOffMarket:     {statusListed, statusUnderContract},
+	statusListed:        {statusUnderContract, statusWithdrawn},
+	statusUnderContract: {statusListed, statusWithdrawn, statusSold},
+	statusSold:          {statusListed, statusUnderContract},
+	statusWithdrawn:     {statusListed},
+}
+
+//leases run between dates in this layout
+const leaseDateLayout = "2006-01-02"
+
+var paymentFrequencies = []string{"weekly", "monthly", "quarterly", "annually"}
+
+const partyPerson = "person"
+const partyCompany = "company"
+const partyTrust = "trust"
+
+var partyTypes = []string{partyPerson, partyCompany, partyTrust}
+
+const kycPending = "pending"
+const kycVerified = "verified"
+const kycRejected = "rejected"
+
+var kycStatuses = []string{kycPending, kycVerified, kycRejected}
+
+const tenancySole = "sole"
+const tenancyInCommon = "tenancy-in-common"
+const tenancyJoint = "joint-tenancy"
+const tenancyCommunity = "community-property"
+
+var tenancyTypes = []string{tenancySole, tenancyInCommon, tenancyJoint, tenancyCommunity}
+
+//largest difference allowed between an owner's share and the percent given with it
+const shareTolerance = 1e-9
+
 type Chaincode struct {}
 
+//Ownership lists the properties an ownership holds. It is bound to the first party recorded
+//for it and can not be held by another party afterwards.
 type Ownership struct {
 	Properties                  []Attribute   	`json:"properties"`
+	PartyId                     string        	`json:"partyId,omitempty"`
 }
 
 type Property struct {
@@ -39,6 +121,263 @@ type Property struct {
 	SaleDate                    string      	`json:"saleDate"`
 	SalePrice                   float64     	`json:"salePrice"`
 	Owners                      []Attribute   	`json:"owners"`
+	Tenancy                     string      	`json:"tenancy,omitempty"`
+	ParcelNumber                string      	`json:"parcelNumber,omitempty"`
+	LegalDescription            string      	`json:"legalDescription,omitempty"`
+	Address                     string      	`json:"address,omitempty"`
+	LandArea                    float64     	`json:"landArea,omitempty"`
+	Zoning                      string      	`json:"zoning,omitempty"`
+	Status                      string      	`json:"status,omitempty"`
+	StatusDate                  string      	`json:"statusDate,omitempty"`
+	LienPayoffs                 []string    	`json:"lienPayoffs,omitempty"`
+	Parents                     []string    	`json:"parents,omitempty"`
+	Children                    []string    	`json:"children,omitempty"`
+	TransferTerms
+}
+
+//TransferTerms says what kind of conveyance recorded the current owners and carries the
+//supporting fields that kind requires. An empty transfer type is a sale.
+type TransferTerms struct {
+	TransferType                string      	`json:"transferType,omitempty"`
+	DeedReference               string      	`json:"deedReference,omitempty"`
+	CaseNumber                  string      	`json:"caseNumber,omitempty"`
+	ForeclosedLienId            string      	`json:"foreclosedLienId,omitempty"`
+	DeathCertificateHash        string      	`json:"deathCertificateHash,omitempty"`
+}
+
+//Lien is a mortgage or other claim against a property that blocks its sale until released
+//or paid off at closing
+type Lien struct {
+	LienId                      string      	`json:"lienId"`
+	PropertyId                  string      	`json:"propertyId"`
+	Holder                      string      	`json:"holder"`
+	Amount                      float64     	`json:"amount"`
+	Priority                    int         	`json:"priority"`
+	RecordingDate               string      	`json:"recordingDate"`
+	RecordTxId                  string      	`json:"recordTxId"`
+	Released                    bool        	`json:"released"`
+	Satisfied                   bool        	`json:"satisfied,omitempty"`
+	ReleaseDate                 string      	`json:"releaseDate,omitempty"`
+	ReleaseTxId                 string      	`json:"releaseTxId,omitempty"`
+	TaxYear                     int         	`json:"taxYear,omitempty"`
+	Deficiency                  float64     	`json:"deficiency,omitempty"`
+}
+
+//TaxBill is a property's assessment and property tax for a year
+type TaxBill struct {
+	PropertyId                  string      	`json:"propertyId"`
+	TaxYear                     int         	`json:"taxYear"`
+	AssessedValue               float64     	`json:"assessedValue"`
+	TaxAmount                   float64     	`json:"taxAmount"`
+	DueDate                     string      	`json:"dueDate"`
+	AmountPaid                  float64     	`json:"amountPaid"`
+	Payments                    []TaxPayment	`json:"payments"`
+	TaxLienId                   string      	`json:"taxLienId,omitempty"`
+}
+
+type TaxPayment struct {
+	Amount                      float64     	`json:"amount"`
+	PaymentDate                 string      	`json:"paymentDate"`
+	TxId                        string      	`json:"txId"`
+}
+
+//TaxDelinquency lists a property's delinquent tax bills
+type TaxDelinquency struct {
+	PropertyId                  string      	`json:"propertyId"`
+	Delinquent                  bool        	`json:"delinquent"`
+	AmountDue                   float64     	`json:"amountDue"`
+	Bills                       []TaxBill   	`json:"bills"`
+}
+
+//Settlement records how the sale price of a closing was paid out
+type Settlement struct {
+	PropertyId                  string      	`json:"propertyId"`
+	TxId                        string      	`json:"txId"`
+	SaleDate                    string      	`json:"saleDate"`
+	SalePrice                   float64     	`json:"salePrice"`
+	LienPayoffs                 []Payout    	`json:"lienPayoffs"`
+	SellerProceeds              []Payout    	`json:"sellerProceeds"`
+	Remainder                   float64     	`json:"remainder"`
+	Shortfalls                  []Payout    	`json:"shortfalls,omitempty"`
+}
+
+//InterestConveyance is one owner's transfer of all or part of its share to grantees, whose
+//shares are given as shares of the whole property
+type InterestConveyance struct {
+	SaleDate                    string      	`json:"saleDate"`
+	SalePrice                   float64     	`json:"salePrice"`
+	Grantees                    []Attribute 	`json:"grantees"`
+	TransferTerms
+}
+
+//BeneficiaryDesignation names who receives an owner's interest in a property on its death.
+//Beneficiary shares are shares of that owner's interest.
+type BeneficiaryDesignation struct {
+	PropertyId                  string      	`json:"propertyId"`
+	OwnershipId                 string      	`json:"ownershipId"`
+	Beneficiaries               []Attribute 	`json:"beneficiaries"`
+	TxId                        string      	`json:"txId"`
+}
+
+//DeathCertificate is the recorded hash of an owner's death certificate
+type DeathCertificate struct {
+	OwnershipId                 string      	`json:"ownershipId"`
+	CertificateHash             string      	`json:"certificateHash"`
+	RecordedBy                  string      	`json:"recordedBy"`
+	RecordedDate                string      	`json:"recordedDate"`
+	TxId                        string      	`json:"txId"`
+}
+
+//Lease lets a tenant occupy a unit of a property, or the whole property when the unit is empty,
+//from its start date to its end date inclusive
+type Lease struct {
+	LeaseId                     string      	`json:"leaseId"`
+	PropertyId                  string      	`json:"propertyId"`
+	Unit                        string      	`json:"unit,omitempty"`
+	Tenant                      string      	`json:"tenant"`
+	StartDate                   string      	`json:"startDate"`
+	EndDate                     string      	`json:"endDate"`
+	RentAmount                  float64     	`json:"rentAmount"`
+	PaymentFrequency            string      	`json:"paymentFrequency"`
+	Deposit                     float64     	`json:"deposit"`
+	CreatedBy                   string      	`json:"createdBy"`
+}
+
+//RentPayment is rent received for a property and how it was split between the owners
+type RentPayment struct {
+	RentId                      string      	`json:"rentId"`
+	PropertyId                  string      	`json:"propertyId"`
+	LeaseId                     string      	`json:"leaseId,omitempty"`
+	Amount                      float64     	`json:"amount"`
+	PaymentDate                 string      	`json:"paymentDate"`
+	RecordedBy                  string      	`json:"recordedBy"`
+	Distributions               []Payout    	`json:"distributions"`
+}
+
+//IncomeEntry is one owner's part of a rent payment. CarriedRemainder is the fraction of a cent
+//the owner is still owed after it.
+type IncomeEntry struct {
+	OwnershipId                 string      	`json:"ownershipId"`
+	PropertyId                  string      	`json:"propertyId"`
+	RentId                      string      	`json:"rentId"`
+	LeaseId                     string      	`json:"leaseId,omitempty"`
+	PaymentDate                 string      	`json:"paymentDate"`
+	Share                       string      	`json:"share"`
+	Cents                       int64       	`json:"cents"`
+	Amount                      float64     	`json:"amount"`
+	CarriedRemainder            string      	`json:"carriedRemainder"`
+}
+
+//OwnerIncome is an ownership's rental income over a date range
+type OwnerIncome struct {
+	OwnershipId                 string      	`json:"ownershipId"`
+	FromDate                    string      	`json:"fromDate"`
+	ToDate                      string      	`json:"toDate"`
+	Entries                     []IncomeEntry	`json:"entries"`
+	Total                       float64     	`json:"total"`
+}
+
+//DocumentReference is a document, such as a deed or closing package, attached to the
+//conveyance txId wrote. Only its SHA-256 hash is kept on the ledger.
+type DocumentReference struct {
+	PropertyId                  string      	`json:"propertyId"`
+	TxId                        string      	`json:"txId"`
+	Type                        string      	`json:"type"`
+	Filename                    string      	`json:"filename"`
+	Hash                        string      	`json:"hash"`
+	Size                        int64       	`json:"size"`
+	Uploader                    string      	`json:"uploader"`
+	AttachTxId                  string      	`json:"attachTxId"`
+}
+
+//DocumentVerification is the result of checking a document hash against the ledger
+type DocumentVerification struct {
+	Hash                        string      	`json:"hash"`
+	Verified                    bool        	`json:"verified"`
+	Documents                   []DocumentReference	`json:"documents"`
+}
+
+//ParcelPlan is a new property created by a split
+type ParcelPlan struct {
+	PropertyId                  string      	`json:"id"`
+	PropertyDetails
+}
+
+//MergePlan combines parent properties into one. Owners is only given when the parents'
+//owners differ.
+type MergePlan struct {
+	Parents                     []string    	`json:"parents"`
+	Details                     PropertyDetails	`json:"details"`
+	Owners                      []Attribute 	`json:"owners,omitempty"`
+	Tenancy                     string      	`json:"tenancy,omitempty"`
+}
+
+//PropertyLineage links a property to the properties it came from and was split or merged into
+type PropertyLineage struct {
+	PropertyId                  string      	`json:"propertyId"`
+	Parents                     []string    	`json:"parents"`
+	Children                    []string    	`json:"children"`
+	Ancestors                   []string    	`json:"ancestors"`
+	Descendants                 []string    	`json:"descendants"`
+}
+
+//ChainOfTitle is the consolidated record of a property's conveyances and liens read by title examiners
+type ChainOfTitle struct {
+	PropertyId                  string      	`json:"propertyId"`
+	ParcelNumber                string      	`json:"parcelNumber,omitempty"`
+	LegalDescription            string      	`json:"legalDescription,omitempty"`
+	Address                     string      	`json:"address,omitempty"`
+	Conveyances                 []Conveyance 	`json:"conveyances"`
+	Liens                       []Lien      	`json:"liens"`
+	Anomalies                   []TitleAnomaly	`json:"anomalies"`
+}
+
+//Conveyance is one change of a property's owners
+type Conveyance struct {
+	Sequence                    int         	`json:"sequence"`
+	TxId                        string      	`json:"txId"`
+	Timestamp                   string      	`json:"timestamp,omitempty"`
+	SaleDate                    string      	`json:"saleDate"`
+	SalePrice                   float64     	`json:"salePrice"`
+	TransferType                string      	`json:"transferType"`
+	Grantors                    []Attribute 	`json:"grantors"`
+	Grantees                    []Attribute 	`json:"grantees"`
+}
+
+//TitleAnomaly is a gap or inconsistency found while building a chain of title
+type TitleAnomaly struct {
+	TxId                        string      	`json:"txId,omitempty"`
+	Type                        string      	`json:"type"`
+	Description                 string      	`json:"description"`
+}
+
+//stateVersion is one write of a key from its history
+type stateVersion struct {
+	Timestamp                   time.Time
+	IsDelete                    bool
+	Value                       []byte
+}
+
+//titleVersion is one committed value of a property key
+type titleVersion struct {
+	TxId                        string
+	Timestamp                   string
+	IsDelete                    bool
+	Property                    Property
+}
+
+//Payout is an amount paid to a lien or an ownership
+type Payout struct {
+	Id                          string      	`json:"id"`
+	Amount                      float64     	`json:"amount"`
+}
+
+type PropertyDetails struct {
+	ParcelNumber                string      	`json:"parcelNumber"`
+	LegalDescription            string      	`json:"legalDescription"`
+	Address                     string      	`json:"address"`
+	LandArea                    float64     	`json:"landArea"`
+	Zoning                      string      	`json:"zoning"`
 }
 
 type Attribute struct {
@@ -46,12 +385,88 @@ type Attribute struct {
 	SaleDate 					string			`json:"saleDate"`
 	Name						string			`json:"name"`
 	Percent                     float64     	`json:"percent"`
+	Share                       string      	`json:"share,omitempty"`
+	PartyId                     string      	`json:"partyId,omitempty"`
+}
+
+//Party is a registered person, company or trust that can own property
+type Party struct {
+	PartyId                     string      	`json:"partyId"`
+	Type                        string      	`json:"type"`
+	LegalName                   string      	`json:"legalName"`
+	Identifiers                 []PartyIdentifier	`json:"identifiers"`
+	KycStatus                   string      	`json:"kycStatus"`
+	KycDate                     string      	`json:"kycDate,omitempty"`
+	Shareholders                []Shareholder	`json:"shareholders,omitempty"`
+	TxId                        string      	`json:"txId"`
+}
+
+//PartyIdentifier is an official identifier of a party, such as a national id or company number
+type PartyIdentifier struct {
+	Type                        string      	`json:"type"`
+	Value                       string      	`json:"value"`
+}
+
+//Shareholder is a party holding a share of a company or trust
+type Shareholder struct {
+	PartyId                     string      	`json:"partyId"`
+	Percent                     float64     	`json:"percent"`
+	Share                       string      	`json:"share,omitempty"`
+}
+
+//BeneficialOwner is a person, or an entity with no recorded shareholders, that ultimately holds
+//a share of a property through the owners above it. Each path runs from a property owner down
+//to the beneficial owner.
+type BeneficialOwner struct {
+	PartyId                     string      	`json:"partyId,omitempty"`
+	OwnershipId                 string      	`json:"ownershipId,omitempty"`
+	LegalName                   string      	`json:"legalName,omitempty"`
+	Type                        string      	`json:"type,omitempty"`
+	Percent                     float64     	`json:"percent"`
+	Share                       string      	`json:"share"`
+	Paths                       [][]string  	`json:"paths"`
+}
+
+type BeneficialOwnership struct {
+	PropertyId                  string      	`json:"propertyId"`
+	Owners                      []BeneficialOwner	`json:"owners"`
+}
+
+//Offer is a buyer's proposed sale, closed once enough current owners accept it
+type Offer struct {
+	OfferId                     string      	`json:"offerId"`
+	PropertyId                  string      	`json:"propertyId"`
+	Buyer                       string      	`json:"buyer"`
+	SaleDate                    string      	`json:"saleDate"`
+	SalePrice                   float64     	`json:"salePrice"`
+	Owners                      []Attribute   	`json:"owners"`
+	Tenancy                     string      	`json:"tenancy,omitempty"`
+	Sellers                     []Attribute   	`json:"sellers"`
+	Approvals                   []Attribute   	`json:"approvals"`
+	LienPayoffs                 []string    	`json:"lienPayoffs,omitempty"`
+	Status                      string      	`json:"status"`
+}
+
+//ownershipShareRecord tells property records from ownership records during migration
+type ownershipShareRecord struct {
+	Owners                      *[]Attribute  	`json:"owners"`
+	Properties                  *[]Attribute  	`json:"properties"`
 }
 
 //chaincode methods
 func (t *Chaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 
-	//No initialization requirements of chain code required at this time
+	//The instantiating identity administers the roles
+	admin, err := getCallerId(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = addRoleToLedger(stub, adminRole, admin)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	return shim.Success(nil)
 
 }
@@ -75,6 +490,102 @@ func (t *Chaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.getProperty(stub, args)
 	}else if args[0] == "getPropertyHistory" {
 		return t.getPropertyHistory(stub, args)
+	} else if args[0] == "registerProperty" {
+		return t.registerProperty(stub, args)
+	} else if args[0] == "updatePropertyDetails" {
+		return t.updatePropertyDetails(stub, args)
+	} else if args[0] == "assignRole" {
+		return t.assignRole(stub, args)
+	} else if args[0] == "migrateOwnershipShares" {
+		return t.migrateOwnershipShares(stub, args)
+	} else if args[0] == "createOffer" {
+		return t.createOffer(stub, args)
+	} else if args[0] == "acceptOffer" {
+		return t.acceptOffer(stub, args)
+	} else if args[0] == "closeSale" {
+		return t.closeSale(stub, args)
+	} else if args[0] == "getOffer" {
+		return t.getOffer(stub, args)
+	} else if args[0] == "getPropertyOffers" {
+		return t.getPropertyOffers(stub, args)
+	} else if args[0] == "assignOwnershipController" {
+		return t.assignOwnershipController(stub, args)
+	} else if args[0] == "setAcceptanceThreshold" {
+		return t.setAcceptanceThreshold(stub, args)
+	} else if args[0] == "listProperty" {
+		return t.listProperty(stub, args)
+	} else if args[0] == "withdrawListing" {
+		return t.withdrawListing(stub, args)
+	} else if args[0] == "markUnderContract" {
+		return t.markUnderContract(stub, args)
+	} else if args[0] == "recordLien" {
+		return t.recordLien(stub, args)
+	} else if args[0] == "releaseLien" {
+		return t.releaseLien(stub, args)
+	} else if args[0] == "getEncumbrances" {
+		return t.getEncumbrances(stub, args)
+	} else if args[0] == "getSettlement" {
+		return t.getSettlement(stub, args)
+	} else if args[0] == "getChainOfTitle" {
+		return t.getChainOfTitle(stub, args)
+	} else if args[0] == "getPropertyAsOf" {
+		return t.getPropertyAsOf(stub, args)
+	} else if args[0] == "getOwnershipAsOf" {
+		return t.getOwnershipAsOf(stub, args)
+	} else if args[0] == "conveyInterest" {
+		return t.conveyInterest(stub, args)
+	} else if args[0] == "setBeneficiaries" {
+		return t.setBeneficiaries(stub, args)
+	} else if args[0] == "getBeneficiaries" {
+		return t.getBeneficiaries(stub, args)
+	} else if args[0] == "executeEstateTransfer" {
+		return t.executeEstateTransfer(stub, args)
+	} else if args[0] == "splitProperty" {
+		return t.splitProperty(stub, args)
+	} else if args[0] == "mergeProperties" {
+		return t.mergeProperties(stub, args)
+	} else if args[0] == "getPropertyLineage" {
+		return t.getPropertyLineage(stub, args)
+	} else if args[0] == "createLease" {
+		return t.createLease(stub, args)
+	} else if args[0] == "getActivePropertyLeases" {
+		return t.getActivePropertyLeases(stub, args)
+	} else if args[0] == "getActiveTenantLeases" {
+		return t.getActiveTenantLeases(stub, args)
+	} else if args[0] == "recordRent" {
+		return t.recordRent(stub, args)
+	} else if args[0] == "getOwnerIncome" {
+		return t.getOwnerIncome(stub, args)
+	} else if args[0] == "recordAssessment" {
+		return t.recordAssessment(stub, args)
+	} else if args[0] == "recordTaxPayment" {
+		return t.recordTaxPayment(stub, args)
+	} else if args[0] == "applyTaxLiens" {
+		return t.applyTaxLiens(stub, args)
+	} else if args[0] == "setTaxDelinquencyDays" {
+		return t.setTaxDelinquencyDays(stub, args)
+	} else if args[0] == "getTaxDelinquency" {
+		return t.getTaxDelinquency(stub, args)
+	} else if args[0] == "getDelinquentProperties" {
+		return t.getDelinquentProperties(stub, args)
+	} else if args[0] == "attachDocument" {
+		return t.attachDocument(stub, args)
+	} else if args[0] == "getConveyanceDocuments" {
+		return t.getConveyanceDocuments(stub, args)
+	} else if args[0] == "verifyDocument" {
+		return t.verifyDocument(stub, args)
+	} else if args[0] == "registerParty" {
+		return t.registerParty(stub, args)
+	} else if args[0] == "updateParty" {
+		return t.updateParty(stub, args)
+	} else if args[0] == "setKycStatus" {
+		return t.setKycStatus(stub, args)
+	} else if args[0] == "getParty" {
+		return t.getParty(stub, args)
+	} else if args[0] == "setShareholders" {
+		return t.setShareholders(stub, args)
+	} else if args[0] == "getBeneficialOwners" {
+		return t.getBeneficialOwners(stub, args)
 	}
 
 	errorMessage = "Invalid method:  " + args[0]
@@ -103,6 +614,39 @@ func (t *Chaincode) getOwnership(stub shim.ChaincodeStubInterface, args []string
 
 }
 
+func (t *Chaincode) getOwnershipAsOf(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(getOwnershipAsOf) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	ownershipId := args[1]
+
+	asOf, err := parseAsOf(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ownershipBytes, err := getStateAsOf(stub, ownershipId, asOf)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ownership := Ownership{}
+	err = json.Unmarshal(ownershipBytes, &ownership)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ownershipBytes, err = json.Marshal(withOwnershipPartyName(stub, ownership))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(ownershipBytes)
+
+}
+
 func (t *Chaincode) getOwnershipHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
 	if len(args) != 2 {
@@ -153,7 +697,7 @@ func (t *Chaincode) getOwnershipHistory(stub shim.ChaincodeStubInterface, args [
 
 			buffer.WriteString("\"ownership\":[")
 
-			ownershipProperties := getOwnershipPropertiesIdValues(ownership.Properties)
+			ownershipProperties := getOwnershipPropertiesIdValues(withOwnershipPartyName(stub, ownership).Properties)
 
 			for i := 0; i < len(ownershipProperties); i++ {
 				buffer.WriteString("{")
@@ -162,6 +706,13 @@ func (t *Chaincode) getOwnershipHistory(stub shim.ChaincodeStubInterface, args [
 				buffer.WriteString("\",\"percent\":")
 				percent := strconv.FormatFloat(ownershipProperties[i].Percent, 'f', 2, 64)
 				buffer.WriteString(percent)
+				share, err := getShare(ownershipProperties[i])
+				if err != nil {
+					return shim.Error(err.Error())
+				}
+				buffer.WriteString(",\"share\":\"")
+				buffer.WriteString(share.RatString())
+				buffer.WriteString("\"")
 				buffer.WriteString(",\"saleDate\":\"")
 
 				propertyAsBytes, err := getPropertyFromLedger(stub, "property_" + ownershipProperties[i].Id)
@@ -175,6 +726,15 @@ func (t *Chaincode) getOwnershipHistory(stub shim.ChaincodeStubInterface, args [
 				buffer.WriteString(ownershipProperties[i].SaleDate)
 				buffer.WriteString("\"")
 
+				if ownershipProperties[i].Name != "" {
+					nameAsBytes, err := json.Marshal(ownershipProperties[i].Name)
+					if err != nil {
+						return shim.Error(err.Error())
+					}
+					buffer.WriteString(",\"name\":")
+					buffer.Write(nameAsBytes)
+				}
+
 				buffer.WriteString("}")
 
 				if i != len(ownership.Properties) - 1{
@@ -217,27 +777,32 @@ func (t *Chaincode) propertyTransaction(stub shim.ChaincodeStubInterface, args [
 		return shim.Error(err.Error())
 	}
 
-	err = verifyValidProperty(property)
+	err = verifySaleTerms(&property)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	err = confirmValidPercentage(property.Owners)
+	propertyBytes, err := stub.GetState(propertyId)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	propertyBytes, err := stub.GetState(propertyId)
+	//only a registrar records the first owners of a property or a sale without the owners'
+	//consent, everyone else buys an owned property through createOffer, acceptOffer and closeSale
+	hasOwners, err := propertyHasOwners(propertyBytes)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	err = updatePropertyOwnership(stub, property, propertyBytes, propertyId)
+	_, err = requireRole(stub, registrarRole)
+	if err != nil && hasOwners {
+		return shim.Error(err.Error() + " Owned properties are sold through createOffer, acceptOffer and closeSale.")
+	}
 	if err != nil {
-		return shim.Error(err.Error())
+		return shim.Error(err.Error() + " Only a registrar records the first owners of a property.")
 	}
 
-	err = addPropertyToLedger(stub, property, propertyId)
+	err = closePropertyTransaction(stub, property, propertyBytes, propertyId)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -262,6 +827,19 @@ func (t *Chaincode) getProperty(stub shim.ChaincodeStubInterface, args []string)
 		return shim.Error(err.Error())
 	}
 
+	property := Property{}
+	err = json.Unmarshal(propertyBytes, &property)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property.Owners = withPartyNames(stub, property.Owners)
+
+	propertyBytes, err = getPropertyAsBytes(property)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	jsonResp := "{\"PropertyId\":\"" + propertyId + "\",\"Property Struct\":\"" + string(propertyBytes) + "\"}"
 	fmt.Printf("Query Response:%s\n", jsonResp)
 
@@ -269,6 +847,41 @@ func (t *Chaincode) getProperty(stub shim.ChaincodeStubInterface, args []string)
 
 }
 
+func (t *Chaincode) getPropertyAsOf(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(getPropertyAsOf) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+
+	asOf, err := parseAsOf(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	propertyBytes, err := getStateAsOf(stub, propertyId, asOf)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property := Property{}
+	err = json.Unmarshal(propertyBytes, &property)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property.Owners = withPartyNames(stub, property.Owners)
+
+	propertyBytes, err = getPropertyAsBytes(property)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(propertyBytes)
+
+}
+
 func (t *Chaincode) getPropertyHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
 	if len(args) != 2 {
@@ -318,8 +931,11 @@ func (t *Chaincode) getPropertyHistory(stub shim.ChaincodeStubInterface, args []
 
 		buffer.WriteString("\"saleDate\":\"" + property.SaleDate + "\",")
 		buffer.WriteString("\"salePrice\":" + strconv.FormatFloat(property.SalePrice, 'f', -1, 64) + ",")
+		buffer.WriteString("\"transferType\":\"" + getTransferType(property.TransferTerms) + "\",")
+		buffer.WriteString("\"status\":\"" + getPropertyStatus(property) + "\",")
+		buffer.WriteString("\"statusDate\":\"" + property.StatusDate + "\",")
 
-		propertyOwners := property.Owners
+		propertyOwners := withPartyNames(stub, property.Owners)
 
 		propertyId := property.PropertyId
 		propertyNumber := strings.Replace(propertyId,"property_","",-1)
@@ -358,319 +974,5550 @@ func (t *Chaincode) getPropertyHistory(stub shim.ChaincodeStubInterface, args []
 
 }
 
-//property transaction methods
-func updatePropertyOwnership(stub shim.ChaincodeStubInterface, newProperty Property, originalPropertyBytes []byte, propertyId string) error{
-	var err error
-	var sameOwnersList = []Attribute{}
-	var updateNewOwnersList = []Attribute{}
-	var updatedOldOwnersList = []Attribute{}
-
-	originalProperty := Property{}
-	if originalPropertyBytes != nil {
-
-		err =json.Unmarshal(originalPropertyBytes, &originalProperty)
-		if err != nil {
-			err = errors.New("Unable to create originalPropertyBytes: " + string(originalPropertyBytes) + ". " + err.Error())
-			return err
-		}
+func (t *Chaincode) getChainOfTitle(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
+	if len(args) != 2 {
+		return shim.Error("(getChainOfTitle) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
 	}
 
-	sameOwnersList, updateNewOwnersList, updatedOldOwnersList = getOwnershipLists(newProperty.Owners, originalProperty.Owners)
+	propertyId := args[1]
 
-	err = removePropertyFromOwnership(stub, updatedOldOwnersList, propertyId)
+	versions, err := getTitleVersions(stub, propertyId)
 	if err != nil {
-		return err
+		return shim.Error(err.Error())
 	}
 
-	err = addPropertyToOwnership(stub, updateNewOwnersList, newProperty)
+	liens, err := getPropertyLiens(stub, propertyId)
 	if err != nil {
-		return err
+		return shim.Error(err.Error())
 	}
 
-	err = updatePropertyForSameOwnership(stub, sameOwnersList, newProperty)
+	chainOfTitle := buildChainOfTitle(propertyId, versions, liens)
+	for i := 0; i < len(chainOfTitle.Conveyances); i++ {
+		chainOfTitle.Conveyances[i].Grantors = withPartyNames(stub, chainOfTitle.Conveyances[i].Grantors)
+		chainOfTitle.Conveyances[i].Grantees = withPartyNames(stub, chainOfTitle.Conveyances[i].Grantees)
+	}
+
+	chainOfTitleAsBytes, err := json.Marshal(chainOfTitle)
 	if err != nil {
-		return err
+		return shim.Error(err.Error())
 	}
 
-	return err
+	return shim.Success(chainOfTitleAsBytes)
 
 }
 
-func updatePropertyForSameOwnership(stub shim.ChaincodeStubInterface, sameOwnersList []Attribute, newProperty Property) error{
+func (t *Chaincode) registerProperty(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	var err error
-	var propertyAttribute = Attribute{}
+	if len(args) != 3 {
+		return shim.Error("(registerProperty) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
 
-	for i := 0; i < len(sameOwnersList); i++ {
+	propertyId := args[1]
+	detailsString := args[2]
 
-		ownershipAsBytes, err := getOwnershipFromLedger(stub, sameOwnersList[i].Id)
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-		ownership := Ownership{}
-		if ownershipAsBytes != nil {
+	propertyBytes, err := stub.GetState(propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if propertyBytes != nil {
+		return shim.Error("Property " + propertyId + " already exists.")
+	}
 
-			err = json.Unmarshal(ownershipAsBytes, &ownership)
-			if err != nil {
-				return err
-			}
+	details := PropertyDetails{}
+	err = json.Unmarshal([]byte(detailsString), &details)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-			for _, v := range ownership.Properties {
+	property := Property{}
+	property.TxId = stub.GetTxID()
+	property.PropertyId = propertyId
+	property.Owners = []Attribute{}
 
-				if v.Id == newProperty.PropertyId {
+	err = updatePropertyDetailsOnLedger(stub, &property, details, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-					ownership.Properties = removePropertyFromOwnershipList(ownership.Properties, newProperty.PropertyId)
+	return shim.Success(nil)
 
-					err = addOwnershipToLedger(stub,ownership, sameOwnersList[i].Id)
-					if err != nil {
-						return err
-					}
+}
 
-					propertyAttribute.Id = newProperty.PropertyId
-					propertyAttribute.SaleDate = newProperty.SaleDate
-					propertyAttribute.Percent = sameOwnersList[i].Percent
-					propertyAttribute.Name = sameOwnersList[i].Name
+func (t *Chaincode) updatePropertyDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-					for j:= 0; j < len(newProperty.Owners); j++ {
+	if len(args) != 3 {
+		return shim.Error("(updatePropertyDetails) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
 
-						if newProperty.Owners[j].Id == sameOwnersList[i].Id {
-							propertyAttribute.Percent = newProperty.Owners[j].Percent
-						}
+	propertyId := args[1]
+	detailsString := args[2]
 
-					}
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	propertyBytes, err := getPropertyFromLedger(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property := Property{}
+	err = json.Unmarshal(propertyBytes, &property)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	details := PropertyDetails{}
+	err = json.Unmarshal([]byte(detailsString), &details)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property.TxId = stub.GetTxID()
+
+	err = updatePropertyDetailsOnLedger(stub, &property, details, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) assignRole(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(assignRole) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	identity := args[1]
+	role := args[2]
+
+	_, err := requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = addRoleToLedger(stub, role, identity)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+//migrateOwnershipShares rewrites up to limit property and ownership records from startKey on
+//so every owner carries an exact share, and returns the key to continue from
+func (t *Chaincode) migrateOwnershipShares(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(migrateOwnershipShares) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	startKey := args[1]
+	limit, err := strconv.Atoi(args[2])
+	if err != nil || limit < 1 {
+		return shim.Error("The limit must be a positive integer.")
+	}
+
+	_, err = requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	resultsIterator, err := stub.GetStateByRange(startKey, "")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	defer resultsIterator.Close()
+
+	migrated := 0
+	scanned := 0
+	nextKey := ""
+
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		//roles and other registry records live under composite keys
+		if strings.HasPrefix(response.Key, "\x00") {
+			continue
+		}
+
+		if scanned == limit {
+			nextKey = response.Key
+			break
+		}
+		scanned++
+
+		record := ownershipShareRecord{}
+		err = json.Unmarshal(response.Value, &record)
+		if err != nil {
+			continue
+		}
+
+		if record.Owners != nil && len(*record.Owners) > 0 && !hasExactShares(*record.Owners) {
+
+			property := Property{}
+			err = json.Unmarshal(response.Value, &property)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+
+			err = migrateOwnerShares(property.Owners)
+			if err != nil {
+				return shim.Error(response.Key + ": " + err.Error())
+			}
+
+			err = addPropertyToLedger(stub, property, response.Key)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			migrated++
+
+		} else if record.Properties != nil && len(*record.Properties) > 0 && !hasExactShares(*record.Properties) {
+
+			ownership := Ownership{}
+			err = json.Unmarshal(response.Value, &ownership)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+
+			err = migrateOwnershipPropertyShares(stub, ownership.Properties, response.Key)
+			if err != nil {
+				return shim.Error(response.Key + ": " + err.Error())
+			}
+
+			err = addOwnershipToLedger(stub, ownership, response.Key)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			migrated++
+
+		}
+
+	}
+
+	jsonResp := "{\"migrated\":" + strconv.Itoa(migrated) + ",\"nextKey\":\"" + nextKey + "\"}"
+
+	return shim.Success([]byte(jsonResp))
+
+}
+
+func (t *Chaincode) createOffer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(createOffer) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+	offerString := args[2]
+
+	buyer, err := getCallerId(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	//the offer carries the terms the buyer wants recorded at closing
+	terms := Property{}
+	err = json.Unmarshal([]byte(offerString), &terms)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if getTransferType(terms.TransferTerms) != transferSale {
+		return shim.Error("Offers can only be made for a sale.")
+	}
+
+	err = verifySaleTerms(&terms)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property, err := getPropertyStruct(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(property.Owners) < 1 {
+		return shim.Error("Property " + propertyId + " has no owners to accept an offer.")
+	}
+
+	err = requireActiveProperty(property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	offer := Offer{}
+	offer.OfferId = stub.GetTxID()
+	offer.PropertyId = propertyId
+	offer.Buyer = buyer
+	offer.SaleDate = terms.SaleDate
+	offer.SalePrice = terms.SalePrice
+	offer.Owners = terms.Owners
+	offer.Tenancy = terms.Tenancy
+	offer.LienPayoffs = terms.LienPayoffs
+	offer.Sellers = property.Owners
+	offer.Approvals = []Attribute{}
+	offer.Status = offerOpen
+
+	err = addOfferToLedger(stub, offer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	offerIndexKey, err := stub.CreateCompositeKey("propertyOffer", []string{propertyId, offer.OfferId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutState(offerIndexKey, []byte(offer.OfferId))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	offerAsBytes, err := json.Marshal(offer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(offerAsBytes)
+
+}
+
+func (t *Chaincode) acceptOffer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(acceptOffer) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	offerId := args[1]
+	ownershipId := args[2]
+
+	err := requireOwnershipController(stub, ownershipId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	offer, err := getOfferFromLedger(stub, offerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if offer.Status != offerOpen && offer.Status != offerAccepted {
+		return shim.Error("Offer " + offerId + " is " + offer.Status + ".")
+	}
+
+	err = confirmSellersUnchanged(stub, offer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var seller *Attribute
+	for i := 0; i < len(offer.Sellers); i++ {
+		if offer.Sellers[i].Id == ownershipId {
+			seller = &offer.Sellers[i]
+		}
+	}
+	if seller == nil {
+		return shim.Error(ownershipId + " is not an owner of " + offer.PropertyId + ".")
+	}
+
+	for i := 0; i < len(offer.Approvals); i++ {
+		if offer.Approvals[i].Id == ownershipId {
+			return shim.Error(ownershipId + " has already accepted offer " + offerId + ".")
+		}
+	}
+
+	offer.Approvals = append(offer.Approvals, *seller)
+
+	accepted, err := hasAcceptanceThreshold(stub, offer.Approvals)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if accepted {
+
+		offer.Status = offerAccepted
+
+		//an accepted offer puts the property under contract when its status allows it
+		property, err := getPropertyStruct(stub, offer.PropertyId)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		if getPropertyStatus(property) != statusUnderContract && transitionPropertyStatus(stub, &property, offer.PropertyId, statusUnderContract) == nil {
+
+			property.TxId = stub.GetTxID()
+
+			err = addPropertyToLedger(stub, property, offer.PropertyId)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+
+		}
+
+	}
+
+	err = addOfferToLedger(stub, offer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) closeSale(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(closeSale) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	offerId := args[1]
+
+	offer, err := getOfferFromLedger(stub, offerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	caller, err := getCallerId(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if caller != offer.Buyer {
+		return shim.Error("Only the buyer can close offer " + offerId + ".")
+	}
+
+	if offer.Status != offerAccepted {
+		return shim.Error("Offer " + offerId + " is " + offer.Status + " and can not be closed.")
+	}
+
+	//the threshold may have been raised since the last acceptance
+	accepted, err := hasAcceptanceThreshold(stub, offer.Approvals)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !accepted {
+		return shim.Error("Offer " + offerId + " does not have the approvals required to close.")
+	}
+
+	err = confirmSellersUnchanged(stub, offer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property := Property{}
+	property.TxId = stub.GetTxID()
+	property.PropertyId = offer.PropertyId
+	property.SaleDate = offer.SaleDate
+	property.SalePrice = offer.SalePrice
+	property.Owners = offer.Owners
+	property.Tenancy = offer.Tenancy
+	property.LienPayoffs = offer.LienPayoffs
+
+	propertyBytes, err := getPropertyFromLedger(stub, offer.PropertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = closePropertyTransaction(stub, property, propertyBytes, offer.PropertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	offer.Status = offerClosed
+
+	err = addOfferToLedger(stub, offer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) getOffer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(getOffer) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	offerId := args[1]
+
+	offerKey, err := stub.CreateCompositeKey("offer", []string{offerId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	offerBytes, err := stub.GetState(offerKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if offerBytes == nil {
+		return shim.Error("{\"Error\":\"Nil amount for " + offerId + "\"}")
+	}
+
+	return shim.Success(offerBytes)
+
+}
+
+func (t *Chaincode) getPropertyOffers(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(getPropertyOffers) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	propertyId := args[1]
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("propertyOffer", []string{propertyId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	defer resultsIterator.Close()
+
+	offers := []Offer{}
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		offer, err := getOfferFromLedger(stub, string(response.Value))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		offers = append(offers, offer)
+
+	}
+
+	offersAsBytes, err := json.Marshal(offers)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(offersAsBytes)
+
+}
+
+func (t *Chaincode) assignOwnershipController(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(assignOwnershipController) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	ownershipId := args[1]
+	identity := args[2]
+
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if strings.TrimSpace(identity) == "" {
+		return shim.Error("An identity is required.")
+	}
+
+	controllerKey, err := stub.CreateCompositeKey("controller", []string{ownershipId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutState(controllerKey, []byte(identity))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) setAcceptanceThreshold(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(setAcceptanceThreshold) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	_, err := requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	threshold, ok := new(big.Rat).SetString(args[1])
+	if !ok || threshold.Sign() <= 0 || threshold.Cmp(big.NewRat(1, 1)) > 0 {
+		return shim.Error("The acceptance threshold must be a share greater than 0 and at most 1.")
+	}
+
+	err = addConfigToLedger(stub, "acceptanceThreshold", threshold.RatString())
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) listProperty(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(listProperty) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	return changePropertyStatus(stub, args[1], args[2], statusListed)
+
+}
+
+func (t *Chaincode) withdrawListing(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(withdrawListing) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	return changePropertyStatus(stub, args[1], args[2], statusWithdrawn)
+
+}
+
+func (t *Chaincode) markUnderContract(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(markUnderContract) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	return changePropertyStatus(stub, args[1], args[2], statusUnderContract)
+
+}
+
+//changePropertyStatus moves a property to status on behalf of one of its current owners
+func changePropertyStatus(stub shim.ChaincodeStubInterface, propertyId string, ownershipId string, status string) pb.Response {
+
+	property, err := getPropertyStruct(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = requirePropertyOwnerController(stub, property, ownershipId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = transitionPropertyStatus(stub, &property, propertyId, status)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property.TxId = stub.GetTxID()
+
+	err = addPropertyToLedger(stub, property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) getSettlement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(getSettlement) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+	txId := args[2]
+
+	settlementKey, err := stub.CreateCompositeKey("settlement", []string{propertyId, txId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	settlementBytes, err := stub.GetState(settlementKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if settlementBytes == nil {
+		return shim.Error("{\"Error\":\"Nil amount for settlement " + txId + " on " + propertyId + "\"}")
+	}
+
+	return shim.Success(settlementBytes)
+
+}
+
+func (t *Chaincode) recordLien(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(recordLien) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+	lienString := args[2]
+
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = getPropertyFromLedger(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lien := Lien{}
+	err = json.Unmarshal([]byte(lienString), &lien)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	//only recordTaxLiens records the liens that pay a tax bill off
+	lien.PropertyId = propertyId
+	lien.Released = false
+	lien.Satisfied = false
+	lien.Deficiency = 0
+	lien.ReleaseDate = ""
+	lien.ReleaseTxId = ""
+	lien.TaxYear = 0
+	lien.RecordTxId = stub.GetTxID()
+
+	if strings.HasPrefix(lien.LienId, taxLienPrefix) {
+		return shim.Error("Lien ids starting with " + taxLienPrefix + " are reserved for tax liens.")
+	}
+
+	if strings.TrimSpace(lien.RecordingDate) == "" {
+		lien.RecordingDate, err = getTxTimestampString(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	err = verifyValidLien(lien)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lienKey, err := stub.CreateCompositeKey("lien", []string{propertyId, lien.LienId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lienBytes, err := stub.GetState(lienKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if lienBytes != nil {
+		return shim.Error("Lien " + lien.LienId + " is already recorded against " + propertyId + ".")
+	}
+
+	err = addLienToLedger(stub, lien)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) releaseLien(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(releaseLien) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+	lienId := args[2]
+
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lien, err := getLienFromLedger(stub, propertyId, lienId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = markLienReleased(stub, lien)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) getEncumbrances(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(getEncumbrances) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	propertyId := args[1]
+
+	liens, err := getPropertyLiens(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	liensAsBytes, err := json.Marshal(liens)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(liensAsBytes)
+
+}
+
+func (t *Chaincode) conveyInterest(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 4 {
+		return shim.Error("(conveyInterest) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 4")
+	}
+
+	propertyId := args[1]
+	ownershipId := args[2]
+	conveyanceString := args[3]
+
+	err := requireOwnershipController(stub, ownershipId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	propertyBytes, err := getPropertyFromLedger(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property := Property{}
+	err = json.Unmarshal(propertyBytes, &property)
+	if err != nil {
+		return shim.Error("Unable to convert property bytes to Property structure. " + err.Error())
+	}
+
+	err = requireActiveProperty(property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	conveyance := InterestConveyance{}
+	err = json.Unmarshal([]byte(conveyanceString), &conveyance)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = verifyValidProperty(Property{SaleDate: conveyance.SaleDate, SalePrice: conveyance.SalePrice, Owners: conveyance.Grantees, TransferTerms: conveyance.TransferTerms})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = requireVerifiedParties(stub, conveyance.Grantees)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	conveyance.Grantees = withoutPartyNames(conveyance.Grantees)
+
+	owners, err := conveyOwnerInterest(property.Owners, ownershipId, conveyance)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = confirmValidPercentage(owners)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = verifyCanConveyInterest(property, ownershipId, owners)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property.TxId = stub.GetTxID()
+	property.SaleDate = conveyance.SaleDate
+	property.SalePrice = conveyance.SalePrice
+	property.Tenancy = getTenancyAfterTransfer(property, owners)
+	property.Owners = owners
+	property.LienPayoffs = nil
+	property.TransferTerms = conveyance.TransferTerms
+
+	if getTransferType(property.TransferTerms) == transferForeclosure {
+		return shim.Error("A foreclosure conveys the whole property and can not convey a single interest.")
+	}
+
+	_, err = recordTaxLiens(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = verifyLienPayoffs(stub, property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = updatePropertyOwnership(stub, property, propertyBytes, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	//the grantor is paid the whole price of the interest it conveyed
+	if property.SalePrice > 0 {
+
+		settlement, err := settleSaleProceeds(stub, property, []Attribute{{Id: ownershipId, Share: "1"}}, []Lien{}, propertyId)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = addSettlementToLedger(stub, settlement)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+	}
+
+	err = addPropertyToLedger(stub, property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) setBeneficiaries(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 4 {
+		return shim.Error("(setBeneficiaries) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 4")
+	}
+
+	propertyId := args[1]
+	ownershipId := args[2]
+	beneficiariesString := args[3]
+
+	property, err := getPropertyStruct(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = requirePropertyOwnerController(stub, property, ownershipId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if hasSurvivorship(property) {
+		return shim.Error("Property " + propertyId + " is held as " + getTenancy(property) + " and passes to the surviving owners.")
+	}
+
+	beneficiaries := []Attribute{}
+	err = json.Unmarshal([]byte(beneficiariesString), &beneficiaries)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = verifyValidBeneficiaries(beneficiaries, ownershipId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	designation := BeneficiaryDesignation{}
+	designation.PropertyId = propertyId
+	designation.OwnershipId = ownershipId
+	designation.Beneficiaries = beneficiaries
+	designation.TxId = stub.GetTxID()
+
+	err = addBeneficiaryDesignationToLedger(stub, designation)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) getBeneficiaries(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(getBeneficiaries) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+	ownershipId := args[2]
+
+	designation, err := getBeneficiaryDesignationFromLedger(stub, propertyId, ownershipId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	designationAsBytes, err := json.Marshal(designation)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(designationAsBytes)
+
+}
+
+//executeEstateTransfer conveys a deceased owner's interest to its transfer-on-death
+//beneficiaries. It is not a sale, so there is no sale price to validate.
+func (t *Chaincode) executeEstateTransfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 4 {
+		return shim.Error("(executeEstateTransfer) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 4")
+	}
+
+	propertyId := args[1]
+	ownershipId := args[2]
+	certificateHash := strings.ToLower(strings.TrimSpace(args[3]))
+
+	executor, err := requireRole(stub, executorRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = recordDeathCertificate(stub, ownershipId, certificateHash, executor)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	propertyBytes, err := getPropertyFromLedger(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property := Property{}
+	err = json.Unmarshal(propertyBytes, &property)
+	if err != nil {
+		return shim.Error("Unable to convert property bytes to Property structure. " + err.Error())
+	}
+
+	err = requireActiveProperty(property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	transferDate, err := getTxTimestampString(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	//joint tenants pass their share to the survivors instead of their beneficiaries
+	var owners []Attribute
+	if hasSurvivorship(property) {
+
+		owners, err = getSurvivingOwners(property.Owners, ownershipId)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+	} else {
+
+		designation, err := getBeneficiaryDesignationFromLedger(stub, propertyId, ownershipId)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		conveyance := InterestConveyance{}
+		conveyance.SaleDate = transferDate
+		conveyance.Grantees, err = getBeneficiaryGrantees(property.Owners, designation)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = requireVerifiedParties(stub, conveyance.Grantees)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		conveyance.Grantees = withoutPartyNames(conveyance.Grantees)
+
+		owners, err = conveyOwnerInterest(property.Owners, ownershipId, conveyance)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+	}
+
+	err = confirmValidPercentage(owners)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	property.TxId = stub.GetTxID()
+	property.SaleDate = transferDate
+	property.SalePrice = 0
+	property.Tenancy = getTenancyAfterTransfer(property, owners)
+	property.Owners = owners
+	property.LienPayoffs = nil
+	property.TransferTerms = TransferTerms{TransferType: transferInheritance, DeathCertificateHash: certificateHash}
+
+	err = updatePropertyOwnership(stub, property, propertyBytes, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	designationKey, err := stub.CreateCompositeKey("beneficiaries", []string{propertyId, ownershipId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.DelState(designationKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = addPropertyToLedger(stub, property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+//splitProperty subdivides a parent property into child properties held by the parent's owners
+//and retires the parent
+func (t *Chaincode) splitProperty(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(splitProperty) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	parentId := args[1]
+	childrenString := args[2]
+
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	children := []ParcelPlan{}
+	err = json.Unmarshal([]byte(childrenString), &children)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(children) < 2 {
+		return shim.Error("A split needs at least 2 child properties.")
+	}
+
+	err = verifyDistinctParcels(children)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	parent, err := getPropertyStruct(stub, parentId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = verifyCanRetire(stub, parent, parentId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	childIds := []string{}
+	for i := 0; i < len(children); i++ {
+
+		err = addLineageChild(stub, children[i], parent.Owners, parent.Tenancy, []string{parentId})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		childIds = append(childIds, children[i].PropertyId)
+
+	}
+
+	err = retireProperty(stub, parent, parentId, childIds)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+//mergeProperties combines parent properties into one new property. The parents must have the
+//same owners unless the merge gives the owners of the new property explicitly.
+func (t *Chaincode) mergeProperties(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(mergeProperties) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+	mergeString := args[2]
+
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	plan := MergePlan{}
+	err = json.Unmarshal([]byte(mergeString), &plan)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(plan.Parents) < 2 {
+		return shim.Error("A merge needs at least 2 parent properties.")
+	}
+
+	parents := []Property{}
+	for i := 0; i < len(plan.Parents); i++ {
+
+		if containsString(plan.Parents[:i], plan.Parents[i]) {
+			return shim.Error("Property " + plan.Parents[i] + " is listed more than once.")
+		}
+
+		parent, err := getPropertyStruct(stub, plan.Parents[i])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = verifyCanRetire(stub, parent, plan.Parents[i])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		parents = append(parents, parent)
+
+	}
+
+	owners := plan.Owners
+	tenancy := plan.Tenancy
+	if len(owners) == 0 {
+
+		owners = parents[0].Owners
+		tenancy = parents[0].Tenancy
+		for i := 1; i < len(parents); i++ {
+			if !haveSameOwners(owners, parents[i].Owners) {
+				return shim.Error("Properties " + plan.Parents[0] + " and " + plan.Parents[i] + " have different owners. Give the owners of the merged property.")
+			}
+			if getTenancy(parents[i]) != getTenancy(parents[0]) {
+				return shim.Error("Properties " + plan.Parents[0] + " and " + plan.Parents[i] + " have different tenancies. Give the owners of the merged property.")
+			}
+		}
+
+	} else {
+
+		err = normalizeShares(owners)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = confirmValidPercentage(owners)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = verifyTenancy(tenancy, owners)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = requireVerifiedParties(stub, owners)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		owners = withoutPartyNames(owners)
+
+	}
+
+	err = addLineageChild(stub, ParcelPlan{PropertyId: propertyId, PropertyDetails: plan.Details}, owners, tenancy, plan.Parents)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	for i := 0; i < len(parents); i++ {
+
+		err = retireProperty(stub, parents[i], plan.Parents[i], []string{propertyId})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) getPropertyLineage(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(getPropertyLineage) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	propertyId := args[1]
+
+	property, err := getPropertyStruct(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lineage := PropertyLineage{}
+	lineage.PropertyId = propertyId
+	lineage.Parents = property.Parents
+	lineage.Children = property.Children
+
+	lineage.Ancestors, err = getRelatedProperties(stub, property, func(p Property) []string { return p.Parents })
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lineage.Descendants, err = getRelatedProperties(stub, property, func(p Property) []string { return p.Children })
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lineageAsBytes, err := json.Marshal(lineage)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(lineageAsBytes)
+
+}
+
+func (t *Chaincode) createLease(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(createLease) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+	leaseString := args[2]
+
+	property, err := getPropertyStruct(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = requireActiveProperty(property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	caller, err := requireMajorityOwnerController(stub, property, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lease := Lease{}
+	err = json.Unmarshal([]byte(leaseString), &lease)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lease.LeaseId = stub.GetTxID()
+	lease.PropertyId = propertyId
+	lease.CreatedBy = caller
+
+	err = verifyValidLease(lease)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	leases, err := getPropertyLeases(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	for i := 0; i < len(leases); i++ {
+		if leasesOverlap(lease, leases[i]) {
+			return shim.Error("Lease " + leases[i].LeaseId + " already covers " + describeLeaseUnit(lease) + " of " + propertyId + " between " + lease.StartDate + " and " + lease.EndDate + ".")
+		}
+	}
+
+	err = addLeaseToLedger(stub, lease)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	tenantLeaseKey, err := stub.CreateCompositeKey("tenantLease", []string{lease.Tenant, propertyId, lease.LeaseId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutState(tenantLeaseKey, []byte(lease.LeaseId))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+//getActivePropertyLeases returns the leases of a property in force on a date
+func (t *Chaincode) getActivePropertyLeases(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(getActivePropertyLeases) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+
+	date, err := parseLeaseDate(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	leases, err := getPropertyLeases(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	leasesAsBytes, err := json.Marshal(getActiveLeases(leases, date))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(leasesAsBytes)
+
+}
+
+//getActiveTenantLeases returns the leases a tenant holds on a date
+func (t *Chaincode) getActiveTenantLeases(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(getActiveTenantLeases) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	tenant := args[1]
+
+	date, err := parseLeaseDate(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("tenantLease", []string{tenant})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	defer resultsIterator.Close()
+
+	leases := []Lease{}
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		_, keyParts, err := stub.SplitCompositeKey(response.Key)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		lease, err := getLeaseFromLedger(stub, keyParts[1], keyParts[2])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		leases = append(leases, lease)
+
+	}
+
+	leasesAsBytes, err := json.Marshal(getActiveLeases(leases, date))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(leasesAsBytes)
+
+}
+
+//recordRent records rent received for a property and splits it between the current owners
+func (t *Chaincode) recordRent(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 4 {
+		return shim.Error("(recordRent) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 4")
+	}
+
+	propertyId := args[1]
+	ownershipId := args[2]
+	rentString := args[3]
+
+	property, err := getPropertyStruct(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = requirePropertyOwnerController(stub, property, ownershipId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	rent := RentPayment{}
+	err = json.Unmarshal([]byte(rentString), &rent)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	rent.RentId = stub.GetTxID()
+	rent.PropertyId = propertyId
+	rent.RecordedBy = ownershipId
+
+	_, err = parseLeaseDate(rent.PaymentDate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if rent.Amount <= 0 {
+		return shim.Error("The rent amount must be greater than 0.")
+	}
+	if rent.LeaseId != "" {
+		_, err = getLeaseFromLedger(stub, propertyId, rent.LeaseId)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	rent.Distributions, err = distributeRent(stub, property, rent)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	rentAsBytes, err := json.Marshal(rent)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	rentKey, err := stub.CreateCompositeKey("rent", []string{propertyId, rent.RentId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutState(rentKey, rentAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(rentAsBytes)
+
+}
+
+//getOwnerIncome returns an ownership's rental income with payment dates from fromDate to
+//toDate inclusive
+func (t *Chaincode) getOwnerIncome(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 4 {
+		return shim.Error("(getOwnerIncome) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 4")
+	}
+
+	ownershipId := args[1]
+
+	fromDate, err := parseLeaseDate(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	toDate, err := parseLeaseDate(args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("income", []string{ownershipId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	defer resultsIterator.Close()
+
+	income := OwnerIncome{}
+	income.OwnershipId = ownershipId
+	income.FromDate = fromDate.Format(leaseDateLayout)
+	income.ToDate = toDate.Format(leaseDateLayout)
+	income.Entries = []IncomeEntry{}
+
+	totalCents := int64(0)
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		entry := IncomeEntry{}
+		err = json.Unmarshal(response.Value, &entry)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		if entry.PaymentDate < income.FromDate || entry.PaymentDate > income.ToDate {
+			continue
+		}
+
+		income.Entries = append(income.Entries, entry)
+		totalCents += entry.Cents
+
+	}
+
+	income.Total = centsToAmount(totalCents)
+
+	incomeAsBytes, err := json.Marshal(income)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(incomeAsBytes)
+
+}
+
+func (t *Chaincode) recordAssessment(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(recordAssessment) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	propertyId := args[1]
+	billString := args[2]
+
+	_, err := requireRole(stub, assessorRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = getPropertyFromLedger(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	bill := TaxBill{}
+	err = json.Unmarshal([]byte(billString), &bill)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	bill.PropertyId = propertyId
+	bill.AmountPaid = 0
+	bill.Payments = []TaxPayment{}
+	bill.TaxLienId = ""
+
+	if bill.TaxYear < 1 {
+		return shim.Error("A tax year is required.")
+	}
+	if bill.AssessedValue <= 0 {
+		return shim.Error("The assessed value must be greater than 0.")
+	}
+	if bill.TaxAmount <= 0 {
+		return shim.Error("The tax amount must be greater than 0.")
+	}
+	_, err = parseLeaseDate(bill.DueDate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = getTaxBillFromLedger(stub, propertyId, bill.TaxYear)
+	if err == nil {
+		return shim.Error("Property " + propertyId + " is already assessed for " + strconv.Itoa(bill.TaxYear) + ".")
+	}
+
+	err = addTaxBillToLedger(stub, bill)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	//a bill assessed after it is due is delinquent as soon as it is recorded
+	_, err = recordTaxLiens(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) recordTaxPayment(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 4 {
+		return shim.Error("(recordTaxPayment) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 4")
+	}
+
+	propertyId := args[1]
+	paymentString := args[3]
+
+	taxYear, err := strconv.Atoi(args[2])
+	if err != nil {
+		return shim.Error("Invalid tax year " + args[2] + ".")
+	}
+
+	_, err = requireRole(stub, assessorRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	payment := TaxPayment{}
+	err = json.Unmarshal([]byte(paymentString), &payment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	payment.TxId = stub.GetTxID()
+
+	if payment.Amount <= 0 {
+		return shim.Error("The payment amount must be greater than 0.")
+	}
+	_, err = parseLeaseDate(payment.PaymentDate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = payTaxBill(stub, propertyId, taxYear, payment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = recordTaxLiens(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+//applyTaxLiens records a tax lien for each of a property's delinquent tax bills that does not
+//have one yet. Assessments, tax payments and transfers record the liens themselves, so this
+//only catches up a property that has had none of those since its bills went delinquent.
+//Anyone may invoke it since the result only depends on the ledger and the transaction time.
+func (t *Chaincode) applyTaxLiens(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(applyTaxLiens) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	propertyId := args[1]
+
+	lienIds, err := recordTaxLiens(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	lienIdsAsBytes, err := json.Marshal(lienIds)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(lienIdsAsBytes)
+
+}
+
+func (t *Chaincode) setTaxDelinquencyDays(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(setTaxDelinquencyDays) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	_, err := requireRole(stub, adminRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	days, err := strconv.Atoi(args[1])
+	if err != nil || days < 0 {
+		return shim.Error("The tax delinquency threshold must be a whole number of days.")
+	}
+
+	err = addConfigToLedger(stub, "taxDelinquencyDays", strconv.Itoa(days))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) getTaxDelinquency(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(getTaxDelinquency) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	propertyId := args[1]
+
+	delinquentBills, err := getDelinquentTaxBills(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	delinquencyAsBytes, err := json.Marshal(getTaxDelinquencyRecord(propertyId, delinquentBills))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(delinquencyAsBytes)
+
+}
+
+func (t *Chaincode) getDelinquentProperties(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return shim.Error("(getDelinquentProperties) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 1")
+	}
+
+	bills, err := getTaxBills(stub, []string{})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	isDelinquent, err := getTaxDelinquencyTest(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	//bills are keyed by property, so each property's bills are adjacent
+	delinquencies := []TaxDelinquency{}
+	delinquentBills := []TaxBill{}
+	for i := 0; i < len(bills); i++ {
+
+		if isDelinquent(bills[i]) {
+			delinquentBills = append(delinquentBills, bills[i])
+		}
+
+		if i == len(bills) - 1 || bills[i + 1].PropertyId != bills[i].PropertyId {
+			if len(delinquentBills) > 0 {
+				delinquencies = append(delinquencies, getTaxDelinquencyRecord(bills[i].PropertyId, delinquentBills))
+			}
+			delinquentBills = []TaxBill{}
+		}
+
+	}
+
+	delinquenciesAsBytes, err := json.Marshal(delinquencies)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(delinquenciesAsBytes)
+
+}
+
+//attachDocument adds a document reference to a conveyance of a property
+func (t *Chaincode) attachDocument(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 4 {
+		return shim.Error("(attachDocument) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 4")
+	}
+
+	propertyId := args[1]
+	txId := args[2]
+	documentString := args[3]
+
+	_, err := getPropertyStruct(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	conveyance, err := getPropertyConveyance(stub, propertyId, txId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	uploader, err := requireDocumentUploader(stub, propertyId, conveyance)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	document := DocumentReference{}
+	err = json.Unmarshal([]byte(documentString), &document)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	document.Hash = strings.ToLower(strings.TrimSpace(document.Hash))
+	document.PropertyId = propertyId
+	document.TxId = txId
+	document.Uploader = uploader
+	document.AttachTxId = stub.GetTxID()
+
+	err = verifyValidDocument(document)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	documentKey, err := stub.CreateCompositeKey("document", []string{propertyId, txId, document.Hash})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	documentBytes, err := stub.GetState(documentKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if documentBytes != nil {
+		return shim.Error("Document " + document.Hash + " is already attached to " + txId + ".")
+	}
+
+	documentAsBytes, err := json.Marshal(document)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutState(documentKey, documentAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	documentHashKey, err := stub.CreateCompositeKey("documentHash", []string{document.Hash, propertyId, txId})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutState(documentHashKey, documentAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) getConveyanceDocuments(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(getConveyanceDocuments) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	documents, err := getDocuments(stub, "document", []string{args[1], args[2]})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	documentsAsBytes, err := json.Marshal(documents)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(documentsAsBytes)
+
+}
+
+//verifyDocument reports the conveyances a document with the given SHA-256 hash is attached to
+func (t *Chaincode) verifyDocument(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(verifyDocument) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	hash := strings.ToLower(strings.TrimSpace(args[1]))
+
+	err := verifySha256Hash(hash, "document")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	verification := DocumentVerification{}
+	verification.Hash = hash
+	verification.Documents, err = getDocuments(stub, "documentHash", []string{hash})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	verification.Verified = len(verification.Documents) > 0
+
+	verificationAsBytes, err := json.Marshal(verification)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(verificationAsBytes)
+
+}
+
+func (t *Chaincode) registerParty(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(registerParty) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	partyId := args[1]
+	partyString := args[2]
+
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = getPartyFromLedger(stub, partyId)
+	if err == nil {
+		return shim.Error("Party " + partyId + " already exists.")
+	}
+
+	party := Party{}
+	err = json.Unmarshal([]byte(partyString), &party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	//new parties wait for compliance to check them and their shareholders are recorded
+	//through setShareholders once those are registered
+	party.PartyId = partyId
+	party.Shareholders = nil
+	party.KycStatus = kycPending
+	party.KycDate = ""
+	party.TxId = stub.GetTxID()
+
+	err = verifyValidParty(party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = addPartyToLedger(stub, party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+//updateParty changes a party's legal name and identifiers. Owners refer to the party by id,
+//so every property it owns shows the new name.
+func (t *Chaincode) updateParty(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(updateParty) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	partyId := args[1]
+	partyString := args[2]
+
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	party, err := getPartyFromLedger(stub, partyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	update := Party{}
+	err = json.Unmarshal([]byte(partyString), &update)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	party.Type = update.Type
+	party.LegalName = update.LegalName
+	party.Identifiers = update.Identifiers
+	party.TxId = stub.GetTxID()
+
+	err = verifyValidParty(party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = addPartyToLedger(stub, party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) setKycStatus(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(setKycStatus) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	partyId := args[1]
+	kycStatus := args[2]
+
+	_, err := requireRole(stub, complianceRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !containsString(kycStatuses, kycStatus) {
+		return shim.Error("Unknown KYC status " + kycStatus + ". Expecting one of " + strings.Join(kycStatuses, ", ") + ".")
+	}
+
+	party, err := getPartyFromLedger(stub, partyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	party.KycStatus = kycStatus
+	party.KycDate, err = getTxTimestampString(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	party.TxId = stub.GetTxID()
+
+	err = addPartyToLedger(stub, party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+func (t *Chaincode) getParty(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(getParty) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	party, err := getPartyFromLedger(stub, args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	partyAsBytes, err := json.Marshal(party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(partyAsBytes)
+
+}
+
+//setShareholders records who holds a company or trust. An empty list clears them so the entity
+//is treated as its own beneficial owner.
+func (t *Chaincode) setShareholders(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return shim.Error("(setShareholders) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 3")
+	}
+
+	partyId := args[1]
+	shareholdersString := args[2]
+
+	_, err := requireRole(stub, registrarRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	party, err := getPartyFromLedger(stub, partyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	shareholders := []Shareholder{}
+	err = json.Unmarshal([]byte(shareholdersString), &shareholders)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	for i := 0; i < len(shareholders); i++ {
+
+		_, err = getPartyFromLedger(stub, shareholders[i].PartyId)
+		if err != nil {
+			return shim.Error("Shareholder " + shareholders[i].PartyId + " is not a registered party.")
+		}
+
+	}
+
+	party.Shareholders = shareholders
+	party.TxId = stub.GetTxID()
+
+	err = verifyValidParty(party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = addPartyToLedger(stub, party)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+
+}
+
+//getBeneficialOwners looks through corporate and trust owners of a property to the parties that
+//ultimately hold it, multiplying shares down each chain
+func (t *Chaincode) getBeneficialOwners(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 2 {
+		return shim.Error("(getBeneficialOwners) Incorrect number of arguments: " + strconv.Itoa(len(args)) + ". Expecting 2")
+	}
+
+	propertyId := args[1]
+
+	property, err := getPropertyStruct(stub, propertyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ownership := BeneficialOwnership{}
+	ownership.PropertyId = propertyId
+	ownership.Owners = []BeneficialOwner{}
+
+	shares := map[string]*big.Rat{}
+	for i := 0; i < len(property.Owners); i++ {
+
+		share, err := getShare(property.Owners[i])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		//owners recorded before the party registry can not be looked through
+		if property.Owners[i].PartyId == "" {
+			ownership.Owners = addBeneficialShare(ownership.Owners, shares, BeneficialOwner{OwnershipId: property.Owners[i].Id, LegalName: property.Owners[i].Name}, share, []string{property.Owners[i].Id})
+			continue
+		}
+
+		ownership.Owners, err = lookThroughParty(stub, ownership.Owners, shares, property.Owners[i].PartyId, share, []string{})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+	}
+
+	for i := 0; i < len(ownership.Owners); i++ {
+		ownership.Owners[i] = withBeneficialShare(ownership.Owners[i], shares[getBeneficialOwnerKey(ownership.Owners[i])])
+	}
+
+	sort.SliceStable(ownership.Owners, func(i, j int) bool {
+		return shares[getBeneficialOwnerKey(ownership.Owners[i])].Cmp(shares[getBeneficialOwnerKey(ownership.Owners[j])]) > 0
+	})
+
+	ownershipAsBytes, err := json.Marshal(ownership)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(ownershipAsBytes)
+
+}
+
+//property transaction methods
+func verifySaleTerms(property *Property) error {
+
+	err := verifyValidProperty(*property)
+	if err != nil {
+		return err
+	}
+
+	err = normalizeShares(property.Owners)
+	if err != nil {
+		return err
+	}
+
+	err = confirmValidPercentage(property.Owners)
+	if err != nil {
+		return err
+	}
+
+	return verifyTenancy(property.Tenancy, property.Owners)
+
+}
+
+//closePropertyTransaction records a validated sale as the property's new state
+func closePropertyTransaction(stub shim.ChaincodeStubInterface, property Property, propertyBytes []byte, propertyId string) error {
+
+	property, err := keepRegisteredDetails(property, propertyBytes)
+	if err != nil {
+		return err
+	}
+
+	originalProperty := Property{}
+	if propertyBytes != nil {
+		err = json.Unmarshal(propertyBytes, &originalProperty)
+		if err != nil {
+			return errors.New("Unable to convert property bytes to Property structure. " + err.Error())
+		}
+	}
+
+	err = requireActiveProperty(originalProperty, propertyId)
+	if err != nil {
+		return err
+	}
+
+	//recording the first owners of a property is not a sale of a listed property, and
+	//only a sale goes through the listing
+	hasOwners := len(originalProperty.Owners) > 0
+	isSale := getTransferType(property.TransferTerms) == transferSale
+	if hasOwners && isSale {
+		err = transitionPropertyStatus(stub, &property, propertyId, statusSold)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = requireVerifiedParties(stub, property.Owners)
+	if err != nil {
+		return err
+	}
+	property.Owners = withoutPartyNames(property.Owners)
+
+	//a foreclosure always pays off the lien being foreclosed and the liens junior to it, and
+	//extinguishes them whether or not the sale price covers them
+	extinguishedLienIds := []string{}
+	if getTransferType(property.TransferTerms) == transferForeclosure {
+
+		extinguishedLienIds, err = getForeclosedLienIds(stub, property.ForeclosedLienId, propertyId)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < len(extinguishedLienIds); i++ {
+			if !containsString(property.LienPayoffs, extinguishedLienIds[i]) {
+				property.LienPayoffs = append(property.LienPayoffs, extinguishedLienIds[i])
+			}
+		}
+
+	}
+
+	//delinquent taxes become a lien that has to be paid off like any other
+	_, err = recordTaxLiens(stub, propertyId)
+	if err != nil {
+		return err
+	}
+
+	paidOffLiens, err := verifyLienPayoffs(stub, property, propertyId)
+	if err != nil {
+		return err
+	}
+
+	err = updatePropertyOwnership(stub, property, propertyBytes, propertyId)
+	if err != nil {
+		return err
+	}
+
+	if (hasOwners && property.SalePrice > 0) || len(paidOffLiens) > 0 {
+
+		settlement, err := settleSaleProceeds(stub, property, originalProperty.Owners, paidOffLiens, propertyId)
+		if err != nil {
+			return err
+		}
+
+		err = addSettlementToLedger(stub, settlement)
+		if err != nil {
+			return err
+		}
+
+		//the lien payoffs are in the same priority order as the paid off liens
+		for i := 0; i < len(paidOffLiens); i++ {
+			extinguish := containsString(extinguishedLienIds, paidOffLiens[i].LienId)
+			err = applyLienPayout(stub, paidOffLiens[i], settlement.LienPayoffs[i].Amount, extinguish, property.SaleDate)
+			if err != nil {
+				return err
+			}
+		}
+
+	}
+
+	return addPropertyToLedger(stub, property, propertyId)
+
+}
+
+func propertyHasOwners(propertyBytes []byte) (bool, error) {
+
+	if propertyBytes == nil {
+		return false, nil
+	}
+
+	property := Property{}
+	err := json.Unmarshal(propertyBytes, &property)
+	if err != nil {
+		return false, errors.New("Unable to convert property bytes to Property structure. " + err.Error())
+	}
+
+	return len(property.Owners) > 0, nil
+
+}
+
+func updatePropertyOwnership(stub shim.ChaincodeStubInterface, newProperty Property, originalPropertyBytes []byte, propertyId string) error{
+	var err error
+	var sameOwnersList = []Attribute{}
+	var updateNewOwnersList = []Attribute{}
+	var updatedOldOwnersList = []Attribute{}
+
+	originalProperty := Property{}
+	if originalPropertyBytes != nil {
+
+		err =json.Unmarshal(originalPropertyBytes, &originalProperty)
+		if err != nil {
+			err = errors.New("Unable to create originalPropertyBytes: " + string(originalPropertyBytes) + ". " + err.Error())
+			return err
+		}
+
+	}
+
+	sameOwnersList, updateNewOwnersList, updatedOldOwnersList = getOwnershipLists(newProperty.Owners, originalProperty.Owners)
+
+	err = removePropertyFromOwnership(stub, updatedOldOwnersList, propertyId)
+	if err != nil {
+		return err
+	}
+
+	err = addPropertyToOwnership(stub, updateNewOwnersList, newProperty)
+	if err != nil {
+		return err
+	}
+
+	err = updatePropertyForSameOwnership(stub, sameOwnersList, newProperty)
+	if err != nil {
+		return err
+	}
+
+	if len(newProperty.Owners) > 0 && !haveSameOwners(originalProperty.Owners, newProperty.Owners) {
+		err = addConveyanceToLedger(stub, originalProperty.Owners, newProperty, propertyId)
+	}
+
+	return err
+
+}
+
+//addConveyanceToLedger records who conveyed a property to whom in this transaction, so the
+//conveyance can be found without reading the property's history
+func addConveyanceToLedger(stub shim.ChaincodeStubInterface, grantors []Attribute, property Property, propertyId string) error {
+
+	timestamp, err := getTxTimestampString(stub)
+	if err != nil {
+		return err
+	}
+
+	conveyance := Conveyance{}
+	conveyance.TxId = stub.GetTxID()
+	conveyance.Timestamp = timestamp
+	conveyance.SaleDate = property.SaleDate
+	conveyance.SalePrice = property.SalePrice
+	conveyance.TransferType = getTransferType(property.TransferTerms)
+	conveyance.Grantors = grantors
+	conveyance.Grantees = property.Owners
+
+	conveyanceAsBytes, err := json.Marshal(conveyance)
+	if err != nil {
+		return errors.New("Unable to convert conveyance to json string " + string(conveyanceAsBytes))
+	}
+
+	conveyanceKey, err := stub.CreateCompositeKey("conveyance", []string{propertyId, conveyance.TxId})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(conveyanceKey, conveyanceAsBytes)
+
+}
+
+func updatePropertyForSameOwnership(stub shim.ChaincodeStubInterface, sameOwnersList []Attribute, newProperty Property) error{
+
+	var err error
+	var propertyAttribute = Attribute{}
+
+	for i := 0; i < len(sameOwnersList); i++ {
+
+		ownershipAsBytes, err := getOwnershipFromLedger(stub, sameOwnersList[i].Id)
+
+		ownership := Ownership{}
+		if ownershipAsBytes != nil {
+
+			err = json.Unmarshal(ownershipAsBytes, &ownership)
+			if err != nil {
+				return err
+			}
+
+			err = bindOwnershipParty(&ownership, sameOwnersList[i])
+			if err != nil {
+				return err
+			}
+
+			for _, v := range ownership.Properties {
+
+				if v.Id == newProperty.PropertyId {
+
+					ownership.Properties = removePropertyFromOwnershipList(ownership.Properties, newProperty.PropertyId)
+
+					err = addOwnershipToLedger(stub,ownership, sameOwnersList[i].Id)
+					if err != nil {
+						return err
+					}
+
+					propertyAttribute.Id = newProperty.PropertyId
+					propertyAttribute.SaleDate = newProperty.SaleDate
+					propertyAttribute.Percent = sameOwnersList[i].Percent
+					propertyAttribute.Share = sameOwnersList[i].Share
+					propertyAttribute.Name = sameOwnersList[i].Name
+
+					for j:= 0; j < len(newProperty.Owners); j++ {
+
+						if newProperty.Owners[j].Id == sameOwnersList[i].Id {
+							propertyAttribute.Percent = newProperty.Owners[j].Percent
+							propertyAttribute.Share = newProperty.Owners[j].Share
+						}
+
+					}
 
 					ownership.Properties = append(ownership.Properties, propertyAttribute)
 
-					err = addOwnershipToLedger(stub, ownership, sameOwnersList[i].Id)
-					if err != nil {
-						return err
-					}
+					err = addOwnershipToLedger(stub, ownership, sameOwnersList[i].Id)
+					if err != nil {
+						return err
+					}
+
+				}
+
+			}
+
+		}else {
+			err = nil
+		}
+
+	}
+
+	return err
+
+}
+
+func addPropertyToOwnership(stub shim.ChaincodeStubInterface, newOwnersList []Attribute, newProperty Property) error{
+
+	var err error
+	var propertyAttribute = Attribute{}
+
+	for i := 0; i < len(newOwnersList); i++ {
+
+		ownershipAsBytes, err := getOwnershipFromLedger(stub, newOwnersList[i].Id)
+
+		propertyAttribute.Id = newProperty.PropertyId
+		propertyAttribute.SaleDate = newProperty.SaleDate
+		propertyAttribute.Percent = newOwnersList[i].Percent
+		propertyAttribute.Share = newOwnersList[i].Share
+		propertyAttribute.Name = newOwnersList[i].Name
+
+		ownership := Ownership{}
+		if ownershipAsBytes != nil {
+
+			err = json.Unmarshal(ownershipAsBytes, &ownership)
+			if err != nil {
+				return err
+			}
+
+		} else {
+			err = nil
+		}
+
+		err = bindOwnershipParty(&ownership, newOwnersList[i])
+		if err != nil {
+			return err
+		}
+
+		ownership.Properties = append(ownership.Properties, propertyAttribute)
+
+		err = addOwnershipToLedger(stub, ownership, newOwnersList[i].Id)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return err
+
+}
+
+func removePropertyFromOwnership(stub shim.ChaincodeStubInterface, oldOwners []Attribute, propertyId string) error{
+
+	var err error
+
+	for i := 0; i < len(oldOwners); i++ {
+
+		ownershipAsBytes, err := getOwnershipFromLedger(stub, oldOwners[i].Id)
+
+		ownership := Ownership{}
+		if ownershipAsBytes != nil {
+
+			err = json.Unmarshal(ownershipAsBytes, &ownership)
+			if err != nil {
+				return err
+			}
+
+			ownership.Properties = removePropertyFromOwnershipList(ownership.Properties, propertyId)
+
+		}else {
+			err = nil
+		}
+
+		err = addOwnershipToLedger(stub, ownership, oldOwners[i].Id)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return err
+
+}
+
+func removePropertyFromOwnershipList(properties []Attribute, propertyId string) []Attribute{
+
+	for i, v := range properties {
+		if v.Id == propertyId {
+			properties[i] = properties[len(properties) - 1]
+			properties = properties[: len(properties) - 1]
+		}
+	}
+
+	return properties
+}
+
+func getOwnershipLists(newOwners []Attribute, oldOwners []Attribute) ([]Attribute, []Attribute, []Attribute){
+	var sameOwners = []Attribute{}
+	var updatedNewOwners = []Attribute{}
+	var updatedOldOwners = []Attribute{}
+
+	if len(newOwners) >= len(oldOwners) {
+		sameOwners, updatedNewOwners, updatedOldOwners = buildOwnershipLists(newOwners, oldOwners)
+	} else {
+		sameOwners, updatedOldOwners, updatedNewOwners = buildOwnershipLists(oldOwners, newOwners)
+	}
+
+	return sameOwners, updatedNewOwners, updatedOldOwners
+
+}
+
+func buildOwnershipLists(longestOwnerList []Attribute, shortestOwnerList []Attribute) ([]Attribute, []Attribute, []Attribute){
+
+	var sameOwnersList = []Attribute{}
+	var updatedLongestOwnerList = []Attribute{}
+	var updatedShortestOwnerList = []Attribute{}
+
+	sameOwnersList, updatedLongestOwnerList = getLongestAndSameOwnershipLists(longestOwnerList, shortestOwnerList)
+
+	updatedShortestOwnerList = getShortestOwnershipList(shortestOwnerList, sameOwnersList)
+
+	return sameOwnersList, updatedLongestOwnerList, updatedShortestOwnerList
+
+}
+
+func getLongestAndSameOwnershipLists(longestOwnersList []Attribute, shortestOwnersList []Attribute) ([]Attribute, []Attribute){
+
+	var sameOwners = []Attribute{}
+	var updatedLongestOwnersList = []Attribute{}
+
+	for i := 0; i < len(longestOwnersList); i++ {
+		var foundMatch = false
+
+		for j := 0; j< len(shortestOwnersList); j++ {
+
+			if longestOwnersList[i].Id == shortestOwnersList[j].Id {
+
+				sameOwners = append(sameOwners, longestOwnersList[i])
+				foundMatch = true
+				break
+
+			} else {
+				foundMatch = false
+			}
+
+		}
+
+		if !foundMatch{
+			updatedLongestOwnersList = append(updatedLongestOwnersList, longestOwnersList[i])
+		}
+
+	}
+
+	return sameOwners, updatedLongestOwnersList
+
+}
+
+func getShortestOwnershipList(shortestOwnersList []Attribute, sameOwnersList []Attribute) ([]Attribute){
+
+	var updatedShortestOwnersList = []Attribute{}
+
+	for k := 0; k <len(shortestOwnersList); k++ {
+
+		var foundMatch = false
+
+		for m:= 0; m < len(sameOwnersList); m++ {
+
+			if shortestOwnersList[k].Id == sameOwnersList[m].Id {
+				foundMatch = true
+				break
+			} else {
+				foundMatch = false
+			}
+
+		}
+
+		if !foundMatch {
+			updatedShortestOwnersList = append(updatedShortestOwnersList, shortestOwnersList[k])
+		}
+
+	}
+
+	return updatedShortestOwnersList
+
+}
+
+func verifyValidProperty(property Property) (error){
+
+	var err error
+
+	if strings.TrimSpace(property.SaleDate) == "" {
+		err = errors.New("A sale date is required.")
+		return err
+	}
+	err = verifyTransferTerms(property.TransferTerms, property.SalePrice)
+	if err != nil {
+		return err
+	}
+	if len(property.Owners) < 1 {
+		err = errors.New("At least one owner is required.")
+	}
+
+	return err
+}
+
+func confirmValidPercentage(buyers []Attribute) error{
+
+	var err error
+
+	//shares are summed exactly so 1/3 + 1/3 + 1/3 and 0.1 + 0.2 + 0.7 both make 1
+	totalShare := new(big.Rat)
+	shares := []*big.Rat{}
+
+	for i := 0; i < len(buyers); i++ {
+
+		share, err := getShare(buyers[i])
+		if err != nil {
+			return err
+		}
+
+		totalShare.Add(totalShare, share)
+		shares = append(shares, share)
+
+	}
+
+	if totalShare.Cmp(big.NewRat(1, 1)) != 0 {
+		totalPercentageString := totalShare.RatString()
+		err = errors.New("Total Percentage can not be greater than or less than 1. Your total percentage =" + totalPercentageString)
+		return err
+	}
+
+	for i := 0; i < len(shares); i++ {
+		if shares[i].Sign() <= 0 {
+			err = errors.New("Each owner's share must be greater than 0.")
+		}
+	}
+
+	return err
+
+}
+
+//share methods
+//getShare returns an owner's exact share. Owners entered before shares existed are read
+//as the decimal their percent was entered as, so 0.45 is 9/20 rather than its float64 value.
+func getShare(owner Attribute) (*big.Rat, error) {
+
+	shareString := strings.TrimSpace(owner.Share)
+	if shareString == "" {
+		shareString = strconv.FormatFloat(owner.Percent, 'f', -1, 64)
+	}
+
+	share, ok := new(big.Rat).SetString(shareString)
+	if !ok {
+		return nil, errors.New("Invalid share " + shareString + " for owner " + owner.Id + ". Expecting a fraction such as 1/3 or a decimal.")
+	}
+
+	return share, nil
+
+}
+
+//normalizeShares accepts owners given as a share, a percent or both, and leaves each with its
+//exact share and the matching percent
+func normalizeShares(owners []Attribute) error {
+
+	for i := 0; i < len(owners); i++ {
+
+		share, err := getShare(owners[i])
+		if err != nil {
+			return err
+		}
+
+		percent, _ := share.Float64()
+		if strings.TrimSpace(owners[i].Share) != "" && owners[i].Percent != 0 && math.Abs(percent - owners[i].Percent) > shareTolerance {
+			return errors.New("Share " + owners[i].Share + " and percent " + strconv.FormatFloat(owners[i].Percent, 'f', -1, 64) + " for owner " + owners[i].Id + " do not agree.")
+		}
+
+		owners[i].Share = share.RatString()
+		owners[i].Percent = percent
+
+	}
+
+	return nil
+
+}
+
+//migrateOwnerShares gives a property's percent-only owners exact shares. Percents such as
+//0.3333333333333333 for thirds do not add up to exactly 1, so when the total is within the
+//share tolerance of 1 the last owner takes what the others leave.
+func migrateOwnerShares(owners []Attribute) error {
+
+	err := normalizeShares(owners)
+	if err != nil || len(owners) == 0 {
+		return err
+	}
+
+	othersTotal := new(big.Rat)
+	for i := 0; i < len(owners) - 1; i++ {
+
+		share, err := getShare(owners[i])
+		if err != nil {
+			return err
+		}
+
+		othersTotal.Add(othersTotal, share)
+
+	}
+
+	lastShare := new(big.Rat).Sub(big.NewRat(1, 1), othersTotal)
+	lastPercent, _ := lastShare.Float64()
+	if lastShare.Sign() <= 0 || math.Abs(lastPercent - owners[len(owners) - 1].Percent) > shareTolerance {
+		return confirmValidPercentage(owners)
+	}
+
+	owners[len(owners) - 1].Share = lastShare.RatString()
+	owners[len(owners) - 1].Percent = lastPercent
+
+	return nil
+
+}
+
+//migrateOwnershipPropertyShares gives an ownership's percent-only properties the same exact
+//share migrateOwnerShares gives the ownership on the property itself
+func migrateOwnershipPropertyShares(stub shim.ChaincodeStubInterface, properties []Attribute, ownershipId string) error {
+
+	for i := 0; i < len(properties); i++ {
+
+		if strings.TrimSpace(properties[i].Share) != "" {
+			continue
+		}
+
+		err := normalizeShares(properties[i:i + 1])
+		if err != nil {
+			return err
+		}
+
+		//a property that is gone or can not be migrated leaves the share its percent gives
+		property, err := getPropertyStruct(stub, properties[i].Id)
+		if err != nil || migrateOwnerShares(property.Owners) != nil {
+			continue
+		}
+
+		for j := 0; j < len(property.Owners); j++ {
+			if property.Owners[j].Id == ownershipId {
+				properties[i].Share = property.Owners[j].Share
+				properties[i].Percent = property.Owners[j].Percent
+			}
+		}
+
+	}
+
+	return nil
+
+}
+
+func hasExactShares(owners []Attribute) bool {
+
+	for i := 0; i < len(owners); i++ {
+		if strings.TrimSpace(owners[i].Share) == "" {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+//conveyOwnerInterest moves the shares given to the grantees out of the grantor's share and
+//returns the recalculated owner list. A grantor left with nothing is removed.
+func conveyOwnerInterest(owners []Attribute, grantorId string, conveyance InterestConveyance) ([]Attribute, error) {
+
+	err := normalizeShares(conveyance.Grantees)
+	if err != nil {
+		return nil, err
+	}
+
+	conveyedShare := new(big.Rat)
+	for i := 0; i < len(conveyance.Grantees); i++ {
+
+		if conveyance.Grantees[i].Id == grantorId {
+			return nil, errors.New("Ownership " + grantorId + " can not convey an interest to itself.")
+		}
+
+		share, err := getShare(conveyance.Grantees[i])
+		if err != nil {
+			return nil, err
+		}
+		if share.Sign() <= 0 {
+			return nil, errors.New("Each grantee's share must be greater than 0.")
+		}
+
+		conveyedShare.Add(conveyedShare, share)
+
+	}
+
+	grantorShare := new(big.Rat)
+	updatedOwners := []Attribute{}
+	for i := 0; i < len(owners); i++ {
+
+		if owners[i].Id != grantorId {
+			updatedOwners = append(updatedOwners, owners[i])
+			continue
+		}
+
+		grantorShare, err = getShare(owners[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if grantorShare.Cmp(conveyedShare) < 0 {
+			return nil, errors.New("Ownership " + grantorId + " holds a share of " + grantorShare.RatString() + " and can not convey " + conveyedShare.RatString() + ".")
+		}
+
+		remainingShare := new(big.Rat).Sub(grantorShare, conveyedShare)
+		if remainingShare.Sign() > 0 {
+			updatedOwners = append(updatedOwners, withShare(owners[i], remainingShare))
+		}
+
+	}
+
+	if grantorShare.Sign() == 0 {
+		return nil, errors.New("Ownership " + grantorId + " is not an owner of this property.")
+	}
+
+	for i := 0; i < len(conveyance.Grantees); i++ {
+
+		grantee := conveyance.Grantees[i]
+		grantee.SaleDate = conveyance.SaleDate
+		share, _ := getShare(grantee)
+
+		foundMatch := false
+		for j := 0; j < len(updatedOwners); j++ {
+
+			if updatedOwners[j].Id == grantee.Id {
+
+				currentShare, err := getShare(updatedOwners[j])
+				if err != nil {
+					return nil, err
+				}
+
+				updatedOwners[j] = withShare(updatedOwners[j], new(big.Rat).Add(currentShare, share))
+				foundMatch = true
+				break
+
+			}
+
+		}
+
+		if !foundMatch {
+			updatedOwners = append(updatedOwners, withShare(grantee, share))
+		}
+
+	}
+
+	return updatedOwners, nil
+
+}
+
+func withShare(owner Attribute, share *big.Rat) Attribute {
+
+	owner.Share = share.RatString()
+	owner.Percent, _ = share.Float64()
+
+	return owner
+
+}
+
+//estate methods
+func verifyValidBeneficiaries(beneficiaries []Attribute, ownershipId string) error {
+
+	if len(beneficiaries) < 1 {
+		return errors.New("At least one beneficiary is required.")
+	}
+
+	for i := 0; i < len(beneficiaries); i++ {
+		if strings.TrimSpace(beneficiaries[i].Id) == "" {
+			return errors.New("Each beneficiary needs an ownership id.")
+		}
+		if beneficiaries[i].Id == ownershipId {
+			return errors.New("Ownership " + ownershipId + " can not be its own beneficiary.")
+		}
+	}
+
+	err := normalizeShares(beneficiaries)
+	if err != nil {
+		return err
+	}
+
+	return confirmValidPercentage(beneficiaries)
+
+}
+
+//getBeneficiaryGrantees splits the deceased's share of the property between its beneficiaries,
+//whose shares are shares of the deceased's interest
+func getBeneficiaryGrantees(owners []Attribute, designation BeneficiaryDesignation) ([]Attribute, error) {
+
+	var deceasedShare *big.Rat
+	for i := 0; i < len(owners); i++ {
+		if owners[i].Id == designation.OwnershipId {
+
+			share, err := getShare(owners[i])
+			if err != nil {
+				return nil, err
+			}
+			deceasedShare = share
+
+		}
+	}
+
+	if deceasedShare == nil {
+		return nil, errors.New(designation.OwnershipId + " is not an owner of " + designation.PropertyId + ".")
+	}
+
+	grantees := []Attribute{}
+	for i := 0; i < len(designation.Beneficiaries); i++ {
+
+		share, err := getShare(designation.Beneficiaries[i])
+		if err != nil {
+			return nil, err
+		}
+
+		grantees = append(grantees, withShare(designation.Beneficiaries[i], new(big.Rat).Mul(deceasedShare, share)))
+
+	}
+
+	return grantees, nil
+
+}
+
+//recordDeathCertificate records the SHA-256 hash of an owner's death certificate the first
+//time an executor presents it, and after that only accepts the same hash
+func recordDeathCertificate(stub shim.ChaincodeStubInterface, ownershipId string, certificateHash string, executor string) error {
+
+	err := verifySha256Hash(certificateHash, "death certificate")
+	if err != nil {
+		return err
+	}
+
+	certificateKey, err := stub.CreateCompositeKey("deathCertificate", []string{ownershipId})
+	if err != nil {
+		return err
+	}
+
+	certificateBytes, err := stub.GetState(certificateKey)
+	if err != nil {
+		return err
+	}
+
+	if certificateBytes != nil {
+
+		certificate := DeathCertificate{}
+		err = json.Unmarshal(certificateBytes, &certificate)
+		if err != nil {
+			return err
+		}
+		if certificate.CertificateHash != certificateHash {
+			return errors.New("The death certificate hash does not match the one recorded for " + ownershipId + ".")
+		}
+
+		return nil
+
+	}
+
+	certificate := DeathCertificate{}
+	certificate.OwnershipId = ownershipId
+	certificate.CertificateHash = certificateHash
+	certificate.RecordedBy = executor
+	certificate.TxId = stub.GetTxID()
+	certificate.RecordedDate, err = getTxTimestampString(stub)
+	if err != nil {
+		return err
+	}
+
+	certificateAsBytes, err := json.Marshal(certificate)
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(certificateKey, certificateAsBytes)
+
+}
+
+func getBeneficiaryDesignationFromLedger(stub shim.ChaincodeStubInterface, propertyId string, ownershipId string) (BeneficiaryDesignation, error) {
+
+	designation := BeneficiaryDesignation{}
+
+	designationKey, err := stub.CreateCompositeKey("beneficiaries", []string{propertyId, ownershipId})
+	if err != nil {
+		return designation, err
+	}
+
+	designationBytes, err := stub.GetState(designationKey)
+	if err != nil {
+		return designation, errors.New("Unable to retrieve beneficiaries for " + ownershipId + ". " + err.Error())
+	}
+	if designationBytes == nil {
+		return designation, errors.New("No beneficiaries are designated by " + ownershipId + " for " + propertyId + ".")
+	}
+
+	err = json.Unmarshal(designationBytes, &designation)
+
+	return designation, err
+
+}
+
+func addBeneficiaryDesignationToLedger(stub shim.ChaincodeStubInterface, designation BeneficiaryDesignation) error {
+
+	designationAsBytes, err := json.Marshal(designation)
+	if err != nil {
+		return errors.New("Unable to convert beneficiaries to json string " + string(designationAsBytes))
+	}
+
+	designationKey, err := stub.CreateCompositeKey("beneficiaries", []string{designation.PropertyId, designation.OwnershipId})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(designationKey, designationAsBytes)
+
+}
+
+//tenancy methods
+//getTenancy returns how a property's owners hold title. Properties recorded before tenancy
+//types existed are held in sole ownership by a single owner and in common otherwise.
+func getTenancy(property Property) string {
+
+	if property.Tenancy != "" {
+		return property.Tenancy
+	}
+	if len(property.Owners) == 1 {
+		return tenancySole
+	}
+
+	return tenancyInCommon
+
+}
+
+//verifyTenancy checks the owners fit the tenancy they take title as. Joint tenants and spouses
+//holding community property must hold equal shares.
+func verifyTenancy(tenancy string, owners []Attribute) error {
+
+	if tenancy == "" {
+		return nil
+	}
+	if !containsString(tenancyTypes, tenancy) {
+		return errors.New("Invalid tenancy " + tenancy + ". Expecting one of " + strings.Join(tenancyTypes, ", ") + ".")
+	}
+
+	switch tenancy {
+	case tenancySole:
+		if len(owners) != 1 {
+			return errors.New("Sole ownership must have exactly 1 owner.")
+		}
+	case tenancyInCommon, tenancyJoint:
+		if len(owners) < 2 {
+			return errors.New("A " + tenancy + " must have at least 2 owners.")
+		}
+	case tenancyCommunity:
+		if len(owners) != 2 {
+			return errors.New("Community property must have exactly 2 owners.")
+		}
+	}
+
+	if tenancy == tenancyJoint || tenancy == tenancyCommunity {
+
+		for i := 1; i < len(owners); i++ {
+
+			firstShare, err := getShare(owners[0])
+			if err != nil {
+				return err
+			}
+
+			share, err := getShare(owners[i])
+			if err != nil {
+				return err
+			}
+
+			if share.Cmp(firstShare) != 0 {
+				return errors.New("Owners holding as " + tenancy + " must hold equal shares.")
+			}
+
+		}
+
+	}
+
+	return nil
+
+}
+
+//verifyCanConveyInterest applies each tenancy's limits on a single owner conveying an interest.
+//Spouses can only convey community property together, and a joint tenant can only convey its
+//whole interest.
+func verifyCanConveyInterest(property Property, grantorId string, owners []Attribute) error {
+
+	switch getTenancy(property) {
+	case tenancyCommunity:
+		return errors.New("Community property can only be conveyed by both spouses through a sale of the whole property.")
+	case tenancyJoint:
+		for i := 0; i < len(owners); i++ {
+			if owners[i].Id == grantorId {
+				return errors.New("Ownership " + grantorId + " is a joint tenant and can only convey its whole interest.")
+			}
+		}
+	}
+
+	return nil
+
+}
+
+//getTenancyAfterTransfer returns the tenancy of a property once an interest has passed to the
+//given owners. Surviving joint tenants keep their joint tenancy. A new owner or unequal shares
+//sever it, so the property is then held in common.
+func getTenancyAfterTransfer(property Property, owners []Attribute) string {
+
+	if property.Tenancy == "" {
+		return ""
+	}
+	if len(owners) == 1 {
+		return tenancySole
+	}
+
+	if property.Tenancy == tenancyJoint {
+
+		survivors := true
+		for i := 0; i < len(owners); i++ {
+
+			foundMatch := false
+			for j := 0; j < len(property.Owners); j++ {
+				if property.Owners[j].Id == owners[i].Id {
+					foundMatch = true
+					break
+				}
+			}
+
+			survivors = survivors && foundMatch
+
+		}
+
+		if survivors && verifyTenancy(tenancyJoint, owners) == nil {
+			return tenancyJoint
+		}
+
+	}
+
+	return tenancyInCommon
+
+}
+
+//hasSurvivorship reports whether a deceased owner's share passes to the surviving owners
+//rather than to its beneficiaries. Only joint tenants have a right of survivorship, a
+//spouse's half of community property passes by will like an interest held in common.
+func hasSurvivorship(property Property) bool {
+
+	return getTenancy(property) == tenancyJoint
+
+}
+
+//getSurvivingOwners removes a deceased owner and spreads its share over the survivors in
+//proportion to their own shares, so equal joint tenants stay equal
+func getSurvivingOwners(owners []Attribute, deceasedId string) ([]Attribute, error) {
+
+	deceasedShare := new(big.Rat)
+	survivors := []Attribute{}
+	for i := 0; i < len(owners); i++ {
+
+		if owners[i].Id != deceasedId {
+			survivors = append(survivors, owners[i])
+			continue
+		}
+
+		share, err := getShare(owners[i])
+		if err != nil {
+			return nil, err
+		}
+		deceasedShare = share
+
+	}
+
+	if deceasedShare.Sign() == 0 {
+		return nil, errors.New("Ownership " + deceasedId + " is not an owner of this property.")
+	}
+	if len(survivors) == 0 {
+		return nil, errors.New("Ownership " + deceasedId + " has no surviving co-owners.")
+	}
+
+	survivingShare := new(big.Rat).Sub(big.NewRat(1, 1), deceasedShare)
+	for i := 0; i < len(survivors); i++ {
+
+		share, err := getShare(survivors[i])
+		if err != nil {
+			return nil, err
+		}
+
+		survivors[i] = withShare(survivors[i], new(big.Rat).Quo(share, survivingShare))
+
+	}
+
+	return survivors, nil
+
+}
+
+//transfer type methods
+func getTransferType(terms TransferTerms) string {
+
+	if terms.TransferType == "" {
+		return transferSale
+	}
+
+	return terms.TransferType
+
+}
+
+//verifyTransferTerms applies the sale price rules and required supporting fields of a
+//conveyance's transfer type
+func verifyTransferTerms(terms TransferTerms, salePrice float64) error {
+
+	transferType := getTransferType(terms)
+
+	switch transferType {
+	case transferSale:
+		if salePrice < 1 {
+			return errors.New("The sale price must be greater than 0.")
+		}
+	case transferGift, transferInheritance:
+		if salePrice != 0 {
+			return errors.New("A " + transferType + " transfer can not have a sale price.")
+		}
+	case transferForeclosure:
+		if salePrice < 1 {
+			return errors.New("The sale price must be greater than 0.")
+		}
+		if strings.TrimSpace(terms.ForeclosedLienId) == "" {
+			return errors.New("A foreclosure requires the foreclosed lien id.")
+		}
+	case transferCourtOrder, transferCorporateRestructuring:
+		if salePrice < 0 {
+			return errors.New("The sale price can not be negative.")
+		}
+	default:
+		return errors.New("Unknown transfer type " + transferType + ".")
+	}
+
+	switch transferType {
+	case transferGift, transferCorporateRestructuring:
+		if strings.TrimSpace(terms.DeedReference) == "" {
+			return errors.New("A " + transferType + " transfer requires a deed reference.")
+		}
+	case transferInheritance:
+		return verifySha256Hash(terms.DeathCertificateHash, "death certificate")
+	case transferForeclosure, transferCourtOrder:
+		if strings.TrimSpace(terms.CaseNumber) == "" {
+			return errors.New("A " + transferType + " transfer requires a court case number.")
+		}
+	}
+
+	return nil
+
+}
+
+func verifySha256Hash(hash string, description string) error {
+
+	hashBytes, err := hex.DecodeString(hash)
+	if err != nil || len(hashBytes) != sha256.Size {
+		return errors.New("The " + description + " hash must be a hex encoded SHA-256 hash.")
+	}
+
+	return nil
+
+}
+
+//listing status methods
+func getPropertyStatus(property Property) string {
+
+	if property.Status == "" {
+		return statusOffMarket
+	}
+
+	return property.Status
+
+}
+
+func transitionPropertyStatus(stub shim.ChaincodeStubInterface, property *Property, propertyId string, status string) error {
+
+	currentStatus := getPropertyStatus(*property)
+
+	allowed := false
+	for _, nextStatus := range propertyStatusTransitions[currentStatus] {
+		if nextStatus == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return errors.New("Property " + propertyId + " is " + currentStatus + " and can not become " + status + ".")
+	}
+
+	statusDate, err := getTxTimestampString(stub)
+	if err != nil {
+		return err
+	}
+
+	property.Status = status
+	property.StatusDate = statusDate
+
+	return nil
+
+}
+
+func requirePropertyOwnerController(stub shim.ChaincodeStubInterface, property Property, ownershipId string) error {
+
+	isOwner := false
+	for i := 0; i < len(property.Owners); i++ {
+		if property.Owners[i].Id == ownershipId {
+			isOwner = true
+		}
+	}
+	if !isOwner {
+		return errors.New(ownershipId + " is not an owner of " + property.PropertyId + ".")
+	}
+
+	return requireOwnershipController(stub, ownershipId)
+
+}
+
+func getTxTimestampString(stub shim.ChaincodeStubInterface) (string, error) {
+
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return "", err
+	}
+	if timestamp == nil {
+		return "", errors.New("The transaction timestamp is not available.")
+	}
+
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC().Format(time.RFC3339), nil
+
+}
+
+//lien methods
+func verifyValidLien(lien Lien) error {
+
+	var err error
+
+	if strings.TrimSpace(lien.LienId) == "" {
+		err = errors.New("A lien id is required.")
+		return err
+	}
+	if strings.TrimSpace(lien.Holder) == "" {
+		err = errors.New("A lien holder is required.")
+		return err
+	}
+	if lien.Amount <= 0 {
+		err = errors.New("The lien amount must be greater than 0.")
+		return err
+	}
+	if lien.Priority < 1 {
+		err = errors.New("The lien priority must be 1 or greater.")
+	}
+
+	return err
+
+}
+
+//verifyLienPayoffs fails a sale while the property has unreleased liens it does not pay off
+//and returns the liens it does pay off in priority order. The liens senior to a foreclosed
+//lien survive the foreclosure and do not have to be paid off.
+func verifyLienPayoffs(stub shim.ChaincodeStubInterface, property Property, propertyId string) ([]Lien, error) {
+
+	liens, err := getUnreleasedLiens(stub, propertyId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(property.LienPayoffs); i++ {
+
+		foundMatch := false
+		for j := 0; j < len(liens); j++ {
+			if liens[j].LienId == property.LienPayoffs[i] {
+				foundMatch = true
+				break
+			}
+		}
+
+		if !foundMatch {
+			return nil, errors.New("Payoff lien " + property.LienPayoffs[i] + " is not an unreleased lien on " + propertyId + ".")
+		}
+
+	}
+
+	unpaidLienIds := []string{}
+	for i := 0; i < len(liens); i++ {
+
+		foundMatch := false
+		for j := 0; j < len(property.LienPayoffs); j++ {
+			if liens[i].LienId == property.LienPayoffs[j] {
+				foundMatch = true
+				break
+			}
+		}
+
+		if !foundMatch {
+			unpaidLienIds = append(unpaidLienIds, liens[i].LienId)
+		}
+
+	}
+
+	if len(unpaidLienIds) > 0 && getTransferType(property.TransferTerms) != transferForeclosure {
+		return nil, errors.New("Property " + propertyId + " has unreleased liens: " + strings.Join(unpaidLienIds, ", ") + ". Release them or include them in lienPayoffs.")
+	}
+
+	//pay the liens off in priority order
+	paidOffLiens := []Lien{}
+	for i := 0; i < len(liens); i++ {
+		for j := 0; j < len(property.LienPayoffs); j++ {
+			if liens[i].LienId == property.LienPayoffs[j] {
+				paidOffLiens = append(paidOffLiens, liens[i])
+				break
+			}
+		}
+	}
+
+	return paidOffLiens, nil
+
+}
+
+//applyLienPayout satisfies and releases a lien its payout covers. A lien the sale price
+//falls short of stays unreleased, securing what is still owed, unless a foreclosure
+//extinguishes it, which releases it and records what is still owed as its deficiency.
+func applyLienPayout(stub shim.ChaincodeStubInterface, lien Lien, payout float64, extinguish bool, paymentDate string) error {
+
+	payoutCents := amountToCents(payout)
+
+	//the tax lien payoff pays the tax bill it was recorded for
+	if isTaxLien(lien) && payoutCents > 0 {
+
+		bill, err := getTaxBillFromLedger(stub, lien.PropertyId, lien.TaxYear)
+		if err != nil {
+			return err
+		}
+
+		bill.Payments = append(bill.Payments, TaxPayment{Amount: centsToAmount(payoutCents), PaymentDate: paymentDate, TxId: stub.GetTxID()})
+		bill.AmountPaid = centsToAmount(amountToCents(bill.AmountPaid) + payoutCents)
+
+		err = addTaxBillToLedger(stub, bill)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	owedCents := amountToCents(lien.Amount) - payoutCents
+	if owedCents <= 0 {
+		lien.Satisfied = true
+		return markLienReleased(stub, lien)
+	}
+
+	if extinguish {
+		lien.Deficiency = centsToAmount(owedCents)
+		return markLienReleased(stub, lien)
+	}
+
+	if payoutCents == 0 {
+		return nil
+	}
+
+	lien.Amount = centsToAmount(owedCents)
+
+	return addLienToLedger(stub, lien)
+
+}
+
+//getForeclosedLienIds returns the foreclosed lien and the unreleased liens junior to it
+func getForeclosedLienIds(stub shim.ChaincodeStubInterface, foreclosedLienId string, propertyId string) ([]string, error) {
+
+	liens, err := getUnreleasedLiens(stub, propertyId)
+	if err != nil {
+		return nil, err
+	}
+
+	lienIds := []string{}
+	for i := 0; i < len(liens); i++ {
+		if liens[i].LienId == foreclosedLienId || len(lienIds) > 0 {
+			lienIds = append(lienIds, liens[i].LienId)
+		}
+	}
+
+	if len(lienIds) == 0 {
+		return nil, errors.New("Foreclosed lien " + foreclosedLienId + " is not an unreleased lien on " + propertyId + ".")
+	}
+
+	return lienIds, nil
+
+}
+
+func markLienReleased(stub shim.ChaincodeStubInterface, lien Lien) error {
+
+	if lien.Released {
+		return errors.New("Lien " + lien.LienId + " on " + lien.PropertyId + " is already released.")
+	}
+
+	releaseDate, err := getTxTimestampString(stub)
+	if err != nil {
+		return err
+	}
+
+	lien.Released = true
+	lien.ReleaseDate = releaseDate
+	lien.ReleaseTxId = stub.GetTxID()
+
+	return addLienToLedger(stub, lien)
+
+}
+
+func getUnreleasedLiens(stub shim.ChaincodeStubInterface, propertyId string) ([]Lien, error) {
+
+	liens, err := getPropertyLiens(stub, propertyId)
+	if err != nil {
+		return nil, err
+	}
+
+	unreleasedLiens := []Lien{}
+	for i := 0; i < len(liens); i++ {
+		if !liens[i].Released {
+			unreleasedLiens = append(unreleasedLiens, liens[i])
+		}
+	}
+
+	return unreleasedLiens, nil
+
+}
+
+//getPropertyLiens returns a property's liens in priority order
+func getPropertyLiens(stub shim.ChaincodeStubInterface, propertyId string) ([]Lien, error) {
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("lien", []string{propertyId})
+	if err != nil {
+		return nil, err
+	}
+
+	defer resultsIterator.Close()
+
+	liens := []Lien{}
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		lien := Lien{}
+		err = json.Unmarshal(response.Value, &lien)
+		if err != nil {
+			return nil, err
+		}
+
+		liens = append(liens, lien)
+
+	}
+
+	sort.SliceStable(liens, func(i, j int) bool {
+		if liens[i].Priority != liens[j].Priority {
+			return liens[i].Priority < liens[j].Priority
+		}
+		return liens[i].RecordingDate < liens[j].RecordingDate
+	})
+
+	return liens, nil
+
+}
+
+func getLienFromLedger(stub shim.ChaincodeStubInterface, propertyId string, lienId string) (Lien, error) {
+
+	lien := Lien{}
+
+	lienKey, err := stub.CreateCompositeKey("lien", []string{propertyId, lienId})
+	if err != nil {
+		return lien, err
+	}
+
+	lienBytes, err := stub.GetState(lienKey)
+	if err != nil {
+		return lien, errors.New("Unable to retrieve lien: " + lienId + ". " + err.Error())
+	}
+	if lienBytes == nil {
+		return lien, errors.New("Nil value for lien: " + lienId + " on " + propertyId)
+	}
+
+	err = json.Unmarshal(lienBytes, &lien)
+
+	return lien, err
+
+}
+
+func addLienToLedger(stub shim.ChaincodeStubInterface, lien Lien) error {
+
+	lienAsBytes, err := json.Marshal(lien)
+	if err != nil {
+		return errors.New("Unable to convert lien to json string " + string(lienAsBytes))
+	}
+
+	lienKey, err := stub.CreateCompositeKey("lien", []string{lien.PropertyId, lien.LienId})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(lienKey, lienAsBytes)
+
+}
+
+//settlement methods
+
+//settleSaleProceeds pays the sale price out to the paid off liens in priority order and
+//splits the remainder between the sellers by share. A lien the sale price does not fully
+//cover gets what is left and its shortfall is recorded.
+func settleSaleProceeds(stub shim.ChaincodeStubInterface, property Property, sellers []Attribute, paidOffLiens []Lien, propertyId string) (Settlement, error) {
+
+	settlement := Settlement{}
+	settlement.PropertyId = propertyId
+	settlement.TxId = stub.GetTxID()
+	settlement.SaleDate = property.SaleDate
+	settlement.SalePrice = property.SalePrice
+	settlement.LienPayoffs = []Payout{}
+	settlement.SellerProceeds = []Payout{}
+
+	remaining := new(big.Rat).SetFloat64(property.SalePrice)
+	if remaining == nil {
+		return settlement, errors.New("Invalid sale price for " + propertyId + ".")
+	}
+
+	for i := 0; i < len(paidOffLiens); i++ {
+
+		payoff := new(big.Rat).SetFloat64(paidOffLiens[i].Amount)
+		if payoff == nil {
+			return settlement, errors.New("Invalid amount for lien " + paidOffLiens[i].LienId + " on " + propertyId + ".")
+		}
+
+		if remaining.Cmp(payoff) < 0 {
+
+			shortfall, _ := new(big.Rat).Sub(payoff, remaining).Float64()
+			settlement.Shortfalls = append(settlement.Shortfalls, Payout{Id: paidOffLiens[i].LienId, Amount: shortfall})
+			payoff.Set(remaining)
+
+		}
+
+		remaining.Sub(remaining, payoff)
+
+		amount, _ := payoff.Float64()
+		settlement.LienPayoffs = append(settlement.LienPayoffs, Payout{Id: paidOffLiens[i].LienId, Amount: amount})
+
+	}
+
+	//the last seller takes what is left so the proceeds always add up to the sale price
+	distributed := new(big.Rat)
+	for i := 0; i < len(sellers); i++ {
+
+		proceeds := new(big.Rat).Sub(remaining, distributed)
+		if i < len(sellers) - 1 {
+
+			share, err := getShare(sellers[i])
+			if err != nil {
+				return settlement, err
+			}
+
+			proceeds.Mul(remaining, share)
+
+		}
+
+		distributed.Add(distributed, proceeds)
+
+		amount, _ := proceeds.Float64()
+		settlement.SellerProceeds = append(settlement.SellerProceeds, Payout{Id: sellers[i].Id, Amount: amount})
+
+	}
+
+	settlement.Remainder, _ = new(big.Rat).Sub(remaining, distributed).Float64()
+
+	return settlement, nil
+
+}
+
+func addSettlementToLedger(stub shim.ChaincodeStubInterface, settlement Settlement) error {
+
+	settlementAsBytes, err := json.Marshal(settlement)
+	if err != nil {
+		return errors.New("Unable to convert settlement to json string " + string(settlementAsBytes))
+	}
+
+	settlementKey, err := stub.CreateCompositeKey("settlement", []string{settlement.PropertyId, settlement.TxId})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(settlementKey, settlementAsBytes)
+
+}
+
+//point in time methods
+func parseAsOf(asOfString string) (time.Time, error) {
+
+	asOf, err := time.Parse(time.RFC3339, strings.TrimSpace(asOfString))
+	if err != nil {
+		return asOf, errors.New("Invalid timestamp " + asOfString + ". Expecting RFC3339 such as 2018-01-15T10:00:00Z.")
+	}
+
+	return asOf, nil
+
+}
+
+//getStateAsOf returns the value a key held at asOf, going by the timestamps of the
+//transactions that wrote it rather than any date stored in the value
+func getStateAsOf(stub shim.ChaincodeStubInterface, key string, asOf time.Time) ([]byte, error) {
+
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, errors.New("Unable to get history for key: " + key + " | " + err.Error())
+	}
+
+	defer resultsIterator.Close()
+
+	versions := []stateVersion{}
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if response.Timestamp == nil {
+			continue
+		}
+
+		writeTime := time.Unix(response.Timestamp.Seconds, int64(response.Timestamp.Nanos))
+		versions = append(versions, stateVersion{Timestamp: writeTime, IsDelete: response.IsDelete, Value: response.Value})
+
+	}
+
+	latestValue := selectStateAsOf(versions, asOf)
+	if latestValue == nil {
+		return nil, errors.New("{\"Error\":\"Nil amount for " + key + " as of " + asOf.UTC().Format(time.RFC3339) + "\"}")
+	}
+
+	return latestValue, nil
+
+}
+
+//getTitleVersions reads every version of a property from its history
+func getTitleVersions(stub shim.ChaincodeStubInterface, propertyId string) ([]titleVersion, error) {
+
+	resultsIterator, err := stub.GetHistoryForKey(propertyId)
+	if err != nil {
+		return nil, errors.New("Unable to get history for key: " + propertyId + " | " + err.Error())
+	}
+
+	defer resultsIterator.Close()
+
+	versions := []titleVersion{}
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		version := titleVersion{}
+		version.TxId = response.TxId
+		version.IsDelete = response.IsDelete
+		if response.Timestamp != nil {
+			version.Timestamp = time.Unix(response.Timestamp.Seconds, int64(response.Timestamp.Nanos)).UTC().Format(time.RFC3339)
+		}
+
+		if !response.IsDelete {
+			err = json.Unmarshal(response.Value, &version.Property)
+			if err != nil {
+				return nil, errors.New("Unable to convert property bytes to Property structure. " + err.Error())
+			}
+		}
+
+		versions = append(versions, version)
+
+	}
+
+	if len(versions) == 0 {
+		return nil, errors.New("{\"Error\":\"Nil amount for " + propertyId + "\"}")
+	}
+
+	return versions, nil
+
+}
+
+//selectStateAsOf returns the value of the latest version written at or before asOf, or nil
+//when the key did not exist yet or had been deleted by then. The versions can be in any order.
+func selectStateAsOf(versions []stateVersion, asOf time.Time) []byte {
+
+	var latestValue []byte
+	var latestTime time.Time
+	found := false
+
+	for i := 0; i < len(versions); i++ {
+
+		writeTime := versions[i].Timestamp
+		if writeTime.After(asOf) || (found && writeTime.Before(latestTime)) {
+			continue
+		}
+
+		found = true
+		latestTime = writeTime
+		latestValue = versions[i].Value
+		if versions[i].IsDelete {
+			latestValue = nil
+		}
+
+	}
+
+	return latestValue
+
+}
+
+//lineage methods
+func requireActiveProperty(property Property, propertyId string) error {
+
+	if getPropertyStatus(property) == statusRetired {
+		return errors.New("Property " + propertyId + " was retired by a split or merge.")
+	}
+
+	return nil
+
+}
+
+//verifyCanRetire checks a property can be split or merged away. Its liens must be released
+//first since they can not follow the land into the new parcels.
+func verifyCanRetire(stub shim.ChaincodeStubInterface, property Property, propertyId string) error {
+
+	err := requireActiveProperty(property, propertyId)
+	if err != nil {
+		return err
+	}
+	if len(property.Owners) < 1 {
+		return errors.New("Property " + propertyId + " has no owners.")
+	}
+
+	liens, err := getUnreleasedLiens(stub, propertyId)
+	if err != nil {
+		return err
+	}
+	if len(liens) > 0 {
+		return errors.New("Property " + propertyId + " has unreleased liens and can not be split or merged.")
+	}
+
+	return nil
+
+}
+
+func verifyDistinctParcels(plans []ParcelPlan) error {
+
+	propertyIds := []string{}
+	parcelNumbers := []string{}
+	for i := 0; i < len(plans); i++ {
+
+		if containsString(propertyIds, plans[i].PropertyId) {
+			return errors.New("Property " + plans[i].PropertyId + " is listed more than once.")
+		}
+		if containsString(parcelNumbers, plans[i].ParcelNumber) {
+			return errors.New("Parcel number " + plans[i].ParcelNumber + " is listed more than once.")
+		}
+
+		propertyIds = append(propertyIds, plans[i].PropertyId)
+		parcelNumbers = append(parcelNumbers, plans[i].ParcelNumber)
+
+	}
+
+	return nil
+
+}
+
+func addLineageChild(stub shim.ChaincodeStubInterface, plan ParcelPlan, owners []Attribute, tenancy string, parents []string) error {
+
+	if strings.TrimSpace(plan.PropertyId) == "" {
+		return errors.New("A property id is required.")
+	}
+
+	propertyBytes, err := stub.GetState(plan.PropertyId)
+	if err != nil {
+		return err
+	}
+	if propertyBytes != nil {
+		return errors.New("Property " + plan.PropertyId + " already exists.")
+	}
+
+	property := Property{}
+	property.TxId = stub.GetTxID()
+	property.PropertyId = plan.PropertyId
+	property.Owners = owners
+	property.Tenancy = tenancy
+	property.Parents = parents
+	property.SaleDate, err = getTxTimestampString(stub)
+	if err != nil {
+		return err
+	}
+
+	err = updatePropertyDetailsOnLedger(stub, &property, plan.PropertyDetails, plan.PropertyId)
+	if err != nil {
+		return err
+	}
+
+	return updatePropertyOwnership(stub, property, nil, plan.PropertyId)
+
+}
+
+//retireProperty links a parent to the properties that replace it and takes it off its owners'
+//ownership records. The retired property keeps its last owners for its history.
+func retireProperty(stub shim.ChaincodeStubInterface, property Property, propertyId string, children []string) error {
+
+	statusDate, err := getTxTimestampString(stub)
+	if err != nil {
+		return err
+	}
+
+	property.Children = children
+	property.Status = statusRetired
+	property.StatusDate = statusDate
+
+	err = removePropertyFromOwnership(stub, property.Owners, propertyId)
+	if err != nil {
+		return err
+	}
+
+	return addPropertyToLedger(stub, property, propertyId)
+
+}
+
+//getRelatedProperties follows the links next returns from property, breadth first, and lists
+//every property reached
+func getRelatedProperties(stub shim.ChaincodeStubInterface, property Property, next func(Property) []string) ([]string, error) {
+
+	related := []string{}
+	queue := next(property)
+
+	for len(queue) > 0 {
+
+		propertyId := queue[0]
+		queue = queue[1:]
+
+		if containsString(related, propertyId) {
+			continue
+		}
+		related = append(related, propertyId)
+
+		relatedProperty, err := getPropertyStruct(stub, propertyId)
+		if err != nil {
+			return nil, err
+		}
+
+		queue = append(queue, next(relatedProperty)...)
+
+	}
+
+	return related, nil
+
+}
+
+//lease methods
+
+//requireMajorityOwnerController checks the caller controls owners holding more than half
+//of a property and returns the caller
+func requireMajorityOwnerController(stub shim.ChaincodeStubInterface, property Property, propertyId string) (string, error) {
+
+	caller, err := getCallerId(stub)
+	if err != nil {
+		return "", err
+	}
+
+	controlledShare := new(big.Rat)
+	for i := 0; i < len(property.Owners); i++ {
+
+		controllerKey, err := stub.CreateCompositeKey("controller", []string{property.Owners[i].Id})
+		if err != nil {
+			return "", err
+		}
+
+		controller, err := stub.GetState(controllerKey)
+		if err != nil {
+			return "", errors.New("Unable to retrieve controller for ownershipId: " + property.Owners[i].Id + ". " + err.Error())
+		}
+		if string(controller) != caller {
+			continue
+		}
+
+		share, err := getShare(property.Owners[i])
+		if err != nil {
+			return "", err
+		}
+
+		controlledShare.Add(controlledShare, share)
+
+	}
+
+	if controlledShare.Cmp(big.NewRat(1, 2)) <= 0 {
+		return "", errors.New(caller + " controls a share of " + controlledShare.RatString() + " of " + propertyId + " and needs a majority share.")
+	}
+
+	return caller, nil
+
+}
+
+func verifyValidLease(lease Lease) error {
+
+	if strings.TrimSpace(lease.Tenant) == "" {
+		return errors.New("A tenant is required.")
+	}
+
+	startDate, err := parseLeaseDate(lease.StartDate)
+	if err != nil {
+		return err
+	}
+	endDate, err := parseLeaseDate(lease.EndDate)
+	if err != nil {
+		return err
+	}
+	if !endDate.After(startDate) {
+		return errors.New("The lease must end after it starts.")
+	}
+
+	if lease.RentAmount <= 0 {
+		return errors.New("The rent amount must be greater than 0.")
+	}
+	if lease.Deposit < 0 {
+		return errors.New("The deposit can not be negative.")
+	}
+	if !containsString(paymentFrequencies, lease.PaymentFrequency) {
+		return errors.New("Unknown payment frequency " + lease.PaymentFrequency + ". Expecting one of " + strings.Join(paymentFrequencies, ", ") + ".")
+	}
+
+	return nil
+
+}
+
+func parseLeaseDate(dateString string) (time.Time, error) {
+
+	date, err := time.Parse(leaseDateLayout, strings.TrimSpace(dateString))
+	if err != nil {
+		return date, errors.New("Invalid date " + dateString + ". Expecting a date such as 2018-01-31.")
+	}
+
+	return date, nil
+
+}
+
+//leasesOverlap reports whether two leases cover the same unit on any day. A lease without a
+//unit covers the whole property and so every unit in it.
+func leasesOverlap(firstLease Lease, secondLease Lease) bool {
+
+	if firstLease.Unit != "" && secondLease.Unit != "" && firstLease.Unit != secondLease.Unit {
+		return false
+	}
+
+	//lease dates share one layout, so they compare as strings
+	return firstLease.StartDate <= secondLease.EndDate && secondLease.StartDate <= firstLease.EndDate
+
+}
+
+func describeLeaseUnit(lease Lease) string {
+
+	if lease.Unit == "" {
+		return "the whole property"
+	}
+
+	return "unit " + lease.Unit
+
+}
+
+func getActiveLeases(leases []Lease, date time.Time) []Lease {
+
+	dateString := date.Format(leaseDateLayout)
+
+	activeLeases := []Lease{}
+	for i := 0; i < len(leases); i++ {
+		if leases[i].StartDate <= dateString && dateString <= leases[i].EndDate {
+			activeLeases = append(activeLeases, leases[i])
+		}
+	}
+
+	return activeLeases
+
+}
+
+func getPropertyLeases(stub shim.ChaincodeStubInterface, propertyId string) ([]Lease, error) {
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("lease", []string{propertyId})
+	if err != nil {
+		return nil, err
+	}
+
+	defer resultsIterator.Close()
+
+	leases := []Lease{}
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		lease := Lease{}
+		err = json.Unmarshal(response.Value, &lease)
+		if err != nil {
+			return nil, err
+		}
+
+		leases = append(leases, lease)
+
+	}
+
+	return leases, nil
+
+}
+
+func getLeaseFromLedger(stub shim.ChaincodeStubInterface, propertyId string, leaseId string) (Lease, error) {
+
+	lease := Lease{}
+
+	leaseKey, err := stub.CreateCompositeKey("lease", []string{propertyId, leaseId})
+	if err != nil {
+		return lease, err
+	}
+
+	leaseBytes, err := stub.GetState(leaseKey)
+	if err != nil {
+		return lease, errors.New("Unable to retrieve lease: " + leaseId + ". " + err.Error())
+	}
+	if leaseBytes == nil {
+		return lease, errors.New("Nil value for lease: " + leaseId + " on " + propertyId)
+	}
+
+	err = json.Unmarshal(leaseBytes, &lease)
+
+	return lease, err
+
+}
+
+func addLeaseToLedger(stub shim.ChaincodeStubInterface, lease Lease) error {
+
+	leaseAsBytes, err := json.Marshal(lease)
+	if err != nil {
+		return errors.New("Unable to convert lease to json string " + string(leaseAsBytes))
+	}
+
+	leaseKey, err := stub.CreateCompositeKey("lease", []string{lease.PropertyId, lease.LeaseId})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(leaseKey, leaseAsBytes)
+
+}
+
+//rental income methods
+
+//distributeRent pays each owner whole cents of its share of the rent. The fraction of a cent
+//an owner is owed is carried forward and added to its share of the next rent for the property,
+//so no rent is lost to rounding and every peer computes the same split.
+func distributeRent(stub shim.ChaincodeStubInterface, property Property, rent RentPayment) ([]Payout, error) {
+
+	rentCents := big.NewRat(amountToCents(rent.Amount), 1)
+
+	distributions := []Payout{}
+	for i := 0; i < len(property.Owners); i++ {
+
+		owner := property.Owners[i]
+
+		share, err := getShare(owner)
+		if err != nil {
+			return nil, err
+		}
+
+		carryKey, err := stub.CreateCompositeKey("rentCarry", []string{rent.PropertyId, owner.Id})
+		if err != nil {
+			return nil, err
+		}
+
+		carry, err := getRentCarry(stub, carryKey)
+		if err != nil {
+			return nil, err
+		}
+
+		owed := new(big.Rat).Mul(rentCents, share)
+		owed.Add(owed, carry)
+
+		//whole cents are paid now and the rest is carried
+		cents := new(big.Int).Quo(owed.Num(), owed.Denom())
+		carry.Sub(owed, new(big.Rat).SetInt(cents))
+
+		err = stub.PutState(carryKey, []byte(carry.RatString()))
+		if err != nil {
+			return nil, err
+		}
+
+		entry := IncomeEntry{}
+		entry.OwnershipId = owner.Id
+		entry.PropertyId = rent.PropertyId
+		entry.RentId = rent.RentId
+		entry.LeaseId = rent.LeaseId
+		entry.PaymentDate = rent.PaymentDate
+		entry.Share = share.RatString()
+		entry.Cents = cents.Int64()
+		entry.Amount = centsToAmount(entry.Cents)
+		entry.CarriedRemainder = carry.RatString()
+
+		err = addIncomeEntryToLedger(stub, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		distributions = append(distributions, Payout{Id: owner.Id, Amount: entry.Amount})
+
+	}
+
+	return distributions, nil
+
+}
+
+func getRentCarry(stub shim.ChaincodeStubInterface, carryKey string) (*big.Rat, error) {
+
+	carryBytes, err := stub.GetState(carryKey)
+	if err != nil {
+		return nil, err
+	}
+	if carryBytes == nil {
+		return new(big.Rat), nil
+	}
+
+	carry, ok := new(big.Rat).SetString(string(carryBytes))
+	if !ok {
+		return nil, errors.New("Invalid rent carry " + string(carryBytes) + ".")
+	}
+
+	return carry, nil
+
+}
+
+func addIncomeEntryToLedger(stub shim.ChaincodeStubInterface, entry IncomeEntry) error {
+
+	entryAsBytes, err := json.Marshal(entry)
+	if err != nil {
+		return errors.New("Unable to convert income entry to json string " + string(entryAsBytes))
+	}
+
+	incomeKey, err := stub.CreateCompositeKey("income", []string{entry.OwnershipId, entry.PaymentDate, entry.PropertyId, entry.RentId})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(incomeKey, entryAsBytes)
+
+}
+
+func amountToCents(amount float64) int64 {
+
+	return int64(math.Round(amount * 100))
+
+}
+
+func centsToAmount(cents int64) float64 {
+
+	return float64(cents) / 100
+
+}
+
+//property tax methods
+func payTaxBill(stub shim.ChaincodeStubInterface, propertyId string, taxYear int, payment TaxPayment) error {
+
+	bill, err := getTaxBillFromLedger(stub, propertyId, taxYear)
+	if err != nil {
+		return err
+	}
+
+	balanceCents := getTaxBalanceCents(bill)
+	if amountToCents(payment.Amount) > balanceCents {
+		return errors.New("The payment of " + strconv.FormatFloat(payment.Amount, 'f', -1, 64) + " is more than the " + strconv.FormatFloat(centsToAmount(balanceCents), 'f', -1, 64) + " owed for " + strconv.Itoa(taxYear) + ".")
+	}
+
+	bill.Payments = append(bill.Payments, payment)
+	bill.AmountPaid = centsToAmount(amountToCents(bill.AmountPaid) + amountToCents(payment.Amount))
+
+	err = addTaxBillToLedger(stub, bill)
+	if err != nil {
+		return err
+	}
+
+	if bill.TaxLienId == "" {
+		return nil
+	}
+
+	lien, err := getLienFromLedger(stub, propertyId, bill.TaxLienId)
+	if err != nil {
+		return err
+	}
+	if lien.Released {
+		return nil
+	}
+
+	//the tax lien secures what is still owed, and a paid bill no longer encumbers the property
+	if getTaxBalanceCents(bill) > 0 {
+		lien.Amount = centsToAmount(getTaxBalanceCents(bill))
+		return addLienToLedger(stub, lien)
+	}
+
+	lien.Satisfied = true
+
+	return markLienReleased(stub, lien)
+
+}
+
+//recordTaxLiens records a tax lien for each of a property's delinquent tax bills that does not
+//have one yet and returns the ids of the liens it recorded
+func recordTaxLiens(stub shim.ChaincodeStubInterface, propertyId string) ([]string, error) {
+
+	lienIds := []string{}
+
+	delinquentBills, err := getDelinquentTaxBills(stub, propertyId)
+	if err != nil {
+		return lienIds, err
+	}
+
+	recordingDate, err := getTxTimestampString(stub)
+	if err != nil {
+		return lienIds, err
+	}
+
+	for i := 0; i < len(delinquentBills); i++ {
+
+		bill := delinquentBills[i]
+		if bill.TaxLienId != "" {
+			continue
+		}
+
+		lienId := getTaxLienId(bill.TaxYear)
+		lienKey, err := stub.CreateCompositeKey("lien", []string{propertyId, lienId})
+		if err != nil {
+			return lienIds, err
+		}
+
+		lienBytes, err := stub.GetState(lienKey)
+		if err != nil {
+			return lienIds, err
+		}
+		if lienBytes != nil {
+			return lienIds, errors.New("Lien " + lienId + " is already recorded against " + propertyId + " and can not be used for the " + strconv.Itoa(bill.TaxYear) + " tax lien.")
+		}
+
+		//tax liens come before every recorded lien
+		lien := Lien{}
+		lien.LienId = lienId
+		lien.PropertyId = propertyId
+		lien.Holder = taxLienHolder
+		lien.Amount = centsToAmount(getTaxBalanceCents(bill))
+		lien.Priority = 0
+		lien.RecordingDate = recordingDate
+		lien.RecordTxId = stub.GetTxID()
+		lien.TaxYear = bill.TaxYear
+
+		err = addLienToLedger(stub, lien)
+		if err != nil {
+			return lienIds, err
+		}
+
+		bill.TaxLienId = lien.LienId
+		err = addTaxBillToLedger(stub, bill)
+		if err != nil {
+			return lienIds, err
+		}
+
+		lienIds = append(lienIds, lien.LienId)
+
+	}
+
+	return lienIds, nil
+
+}
+
+func getTaxBalanceCents(bill TaxBill) int64 {
+
+	return amountToCents(bill.TaxAmount) - amountToCents(bill.AmountPaid)
+
+}
+
+func getTaxDelinquencyRecord(propertyId string, delinquentBills []TaxBill) TaxDelinquency {
+
+	delinquency := TaxDelinquency{}
+	delinquency.PropertyId = propertyId
+	delinquency.Delinquent = len(delinquentBills) > 0
+	delinquency.Bills = delinquentBills
+
+	amountDueCents := int64(0)
+	for i := 0; i < len(delinquentBills); i++ {
+		amountDueCents += getTaxBalanceCents(delinquentBills[i])
+	}
+	delinquency.AmountDue = centsToAmount(amountDueCents)
+
+	return delinquency
+
+}
+
+func getDelinquentTaxBills(stub shim.ChaincodeStubInterface, propertyId string) ([]TaxBill, error) {
+
+	bills, err := getTaxBills(stub, []string{propertyId})
+	if err != nil {
+		return nil, err
+	}
+
+	isDelinquent, err := getTaxDelinquencyTest(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	delinquentBills := []TaxBill{}
+	for i := 0; i < len(bills); i++ {
+		if isDelinquent(bills[i]) {
+			delinquentBills = append(delinquentBills, bills[i])
+		}
+	}
+
+	return delinquentBills, nil
+
+}
+
+//getTaxDelinquencyTest returns a test for bills left unpaid more than the delinquency
+//threshold past their due date at the transaction time
+func getTaxDelinquencyTest(stub shim.ChaincodeStubInterface) (func(TaxBill) bool, error) {
+
+	daysString, err := getConfigFromLedger(stub, "taxDelinquencyDays")
+	if err != nil {
+		return nil, err
+	}
+	if daysString == "" {
+		daysString = defaultTaxDelinquencyDays
+	}
+
+	days, err := strconv.Atoi(daysString)
+	if err != nil {
+		return nil, errors.New("Invalid tax delinquency threshold: " + daysString)
+	}
+
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if timestamp == nil {
+		return nil, errors.New("The transaction timestamp is not available.")
+	}
+	now := time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC()
+
+	return func(bill TaxBill) bool {
+
+		dueDate, err := parseLeaseDate(bill.DueDate)
+		if err != nil {
+			return false
+		}
+
+		return getTaxBalanceCents(bill) > 0 && now.After(dueDate.AddDate(0, 0, days + 1))
+
+	}, nil
+
+}
+
+func getTaxBills(stub shim.ChaincodeStubInterface, keys []string) ([]TaxBill, error) {
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("taxBill", keys)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resultsIterator.Close()
+
+	bills := []TaxBill{}
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		bill := TaxBill{}
+		err = json.Unmarshal(response.Value, &bill)
+		if err != nil {
+			return nil, err
+		}
+
+		bills = append(bills, bill)
+
+	}
+
+	return bills, nil
+
+}
+
+func getTaxBillFromLedger(stub shim.ChaincodeStubInterface, propertyId string, taxYear int) (TaxBill, error) {
+
+	bill := TaxBill{}
+
+	billKey, err := getTaxBillKey(stub, propertyId, taxYear)
+	if err != nil {
+		return bill, err
+	}
+
+	billBytes, err := stub.GetState(billKey)
+	if err != nil {
+		return bill, errors.New("Unable to retrieve tax bill: " + strconv.Itoa(taxYear) + ". " + err.Error())
+	}
+	if billBytes == nil {
+		return bill, errors.New("Nil value for tax bill: " + strconv.Itoa(taxYear) + " on " + propertyId)
+	}
+
+	err = json.Unmarshal(billBytes, &bill)
+
+	return bill, err
+
+}
+
+func addTaxBillToLedger(stub shim.ChaincodeStubInterface, bill TaxBill) error {
+
+	billAsBytes, err := json.Marshal(bill)
+	if err != nil {
+		return errors.New("Unable to convert tax bill to json string " + string(billAsBytes))
+	}
+
+	billKey, err := getTaxBillKey(stub, bill.PropertyId, bill.TaxYear)
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(billKey, billAsBytes)
+
+}
+
+//getTaxLienId returns the reserved id of the tax lien for a year's tax bill
+func getTaxLienId(taxYear int) string {
+
+	return taxLienPrefix + strconv.Itoa(taxYear)
+
+}
+
+//isTaxLien reports whether recordTaxLiens recorded the lien for a tax bill
+func isTaxLien(lien Lien) bool {
+
+	return lien.TaxYear > 0 && lien.Holder == taxLienHolder && lien.LienId == getTaxLienId(lien.TaxYear)
+
+}
+
+//getTaxBillKey pads the tax year so a property's bills are listed oldest first
+func getTaxBillKey(stub shim.ChaincodeStubInterface, propertyId string, taxYear int) (string, error) {
+
+	return stub.CreateCompositeKey("taxBill", []string{propertyId, fmt.Sprintf("%04d", taxYear)})
+
+}
+
+//document methods
+
+//requireDocumentUploader checks the caller is a registrar or controls an ownership that was a
+//grantor or grantee of the conveyance the document is attached to
+func requireDocumentUploader(stub shim.ChaincodeStubInterface, propertyId string, conveyance Conveyance) (string, error) {
+
+	caller, err := getCallerId(stub)
+	if err != nil {
+		return "", err
+	}
+
+	isRegistrar, err := callerHasRole(stub, registrarRole, caller)
+	if err != nil {
+		return "", err
+	}
+	if isRegistrar {
+		return caller, nil
+	}
+
+	parties := append(append([]Attribute{}, conveyance.Grantors...), conveyance.Grantees...)
+	for i := 0; i < len(parties); i++ {
+		if requireOwnershipController(stub, parties[i].Id) == nil {
+			return caller, nil
+		}
+	}
 
-				}
+	return "", errors.New(caller + " can not attach documents to " + propertyId + " for transaction " + conveyance.TxId + ".")
+
+}
+
+//getPropertyConveyance returns the conveyance txId made of the property. Transactions that did
+//not change the owners are not conveyances. Conveyances recorded before they were kept on
+//the ledger are found through the property's history.
+func getPropertyConveyance(stub shim.ChaincodeStubInterface, propertyId string, txId string) (Conveyance, error) {
+
+	conveyance := Conveyance{}
+
+	if strings.TrimSpace(txId) == "" {
+		return conveyance, errors.New("A transaction id is required.")
+	}
+
+	conveyanceKey, err := stub.CreateCompositeKey("conveyance", []string{propertyId, txId})
+	if err != nil {
+		return conveyance, err
+	}
+
+	conveyanceBytes, err := stub.GetState(conveyanceKey)
+	if err != nil {
+		return conveyance, err
+	}
+	if conveyanceBytes != nil {
+		err = json.Unmarshal(conveyanceBytes, &conveyance)
+		return conveyance, err
+	}
+
+	versions, err := getTitleVersions(stub, propertyId)
+	if err != nil {
+		return conveyance, err
+	}
+
+	chainOfTitle := buildChainOfTitle(propertyId, versions, []Lien{})
+	for i := 0; i < len(chainOfTitle.Conveyances); i++ {
+		if chainOfTitle.Conveyances[i].TxId == txId {
+			return chainOfTitle.Conveyances[i], nil
+		}
+	}
+
+	return conveyance, errors.New("Transaction " + txId + " is not a conveyance of " + propertyId + ".")
+
+}
+
+func verifyValidDocument(document DocumentReference) error {
+
+	if strings.TrimSpace(document.Type) == "" {
+		return errors.New("A document type is required.")
+	}
+	if strings.TrimSpace(document.Filename) == "" {
+		return errors.New("A document filename is required.")
+	}
+	if document.Size <= 0 {
+		return errors.New("The document size must be greater than 0.")
+	}
+
+	return verifySha256Hash(document.Hash, "document")
+
+}
+
+func getDocuments(stub shim.ChaincodeStubInterface, objectType string, keys []string) ([]DocumentReference, error) {
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resultsIterator.Close()
+
+	documents := []DocumentReference{}
+	for resultsIterator.HasNext() {
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		document := DocumentReference{}
+		err = json.Unmarshal(response.Value, &document)
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, document)
+
+	}
+
+	return documents, nil
+
+}
+
+//party methods
+func verifyValidParty(party Party) error {
+
+	if strings.TrimSpace(party.PartyId) == "" {
+		return errors.New("A party id is required.")
+	}
+	if !containsString(partyTypes, party.Type) {
+		return errors.New("Unknown party type " + party.Type + ". Expecting one of " + strings.Join(partyTypes, ", ") + ".")
+	}
+	if strings.TrimSpace(party.LegalName) == "" {
+		return errors.New("A legal name is required.")
+	}
+
+	for i := 0; i < len(party.Identifiers); i++ {
+		if strings.TrimSpace(party.Identifiers[i].Type) == "" || strings.TrimSpace(party.Identifiers[i].Value) == "" {
+			return errors.New("Each identifier needs a type and a value.")
+		}
+	}
+
+	return verifyValidShareholders(party)
+
+}
+
+//requireVerifiedParties checks every new owner refers to a registered party that has passed KYC
+func requireVerifiedParties(stub shim.ChaincodeStubInterface, owners []Attribute) error {
+
+	for i := 0; i < len(owners); i++ {
+
+		if strings.TrimSpace(owners[i].PartyId) == "" {
+			return errors.New("Owner " + owners[i].Id + " needs a party id.")
+		}
+
+		party, err := getPartyFromLedger(stub, owners[i].PartyId)
+		if err != nil {
+			return errors.New("Owner " + owners[i].Id + " refers to unknown party " + owners[i].PartyId + ".")
+		}
+		if party.KycStatus != kycVerified {
+			return errors.New("Party " + party.PartyId + " is not KYC verified. Its status is " + party.KycStatus + ".")
+		}
+
+	}
+
+	return nil
+
+}
+
+//bindOwnershipParty binds an ownership to the party of the owner holding it the first time a
+//party is given, and fails when the owner names a different party afterwards
+func bindOwnershipParty(ownership *Ownership, owner Attribute) error {
+
+	if ownership.PartyId == "" {
+		ownership.PartyId = owner.PartyId
+		return nil
+	}
+
+	if owner.PartyId != ownership.PartyId {
+		return errors.New("Ownership " + owner.Id + " is bound to party " + ownership.PartyId + " and can not be held by party " + owner.PartyId + ".")
+	}
+
+	return nil
+
+}
+
+//withoutPartyNames drops the names of owners bound to a party before they are stored, since
+//withPartyNames reads them from the party registry
+func withoutPartyNames(owners []Attribute) []Attribute {
+
+	unnamedOwners := []Attribute{}
+	for i := 0; i < len(owners); i++ {
+
+		owner := owners[i]
+		if owner.PartyId != "" {
+			owner.Name = ""
+		}
+
+		unnamedOwners = append(unnamedOwners, owner)
+
+	}
+
+	return unnamedOwners
+
+}
+
+//withPartyNames fills in owner names from the party registry so they follow renames
+func withPartyNames(stub shim.ChaincodeStubInterface, owners []Attribute) []Attribute {
+
+	namedOwners := []Attribute{}
+	for i := 0; i < len(owners); i++ {
+
+		owner := owners[i]
+		if owner.PartyId != "" {
+			party, err := getPartyFromLedger(stub, owner.PartyId)
+			if err == nil {
+				owner.Name = party.LegalName
+			}
+		}
+
+		namedOwners = append(namedOwners, owner)
+
+	}
+
+	return namedOwners
+
+}
+
+//withOwnershipPartyName fills in the names of an ownership's properties from the party the
+//ownership is bound to
+func withOwnershipPartyName(stub shim.ChaincodeStubInterface, ownership Ownership) Ownership {
+
+	if ownership.PartyId == "" {
+		return ownership
+	}
+
+	party, err := getPartyFromLedger(stub, ownership.PartyId)
+	if err != nil {
+		return ownership
+	}
+
+	namedProperties := []Attribute{}
+	for i := 0; i < len(ownership.Properties); i++ {
+		property := ownership.Properties[i]
+		property.Name = party.LegalName
+		namedProperties = append(namedProperties, property)
+	}
+	ownership.Properties = namedProperties
+
+	return ownership
+
+}
+
+func getPartyFromLedger(stub shim.ChaincodeStubInterface, partyId string) (Party, error) {
+
+	party := Party{}
+
+	partyKey, err := stub.CreateCompositeKey("party", []string{partyId})
+	if err != nil {
+		return party, err
+	}
+
+	partyBytes, err := stub.GetState(partyKey)
+	if err != nil {
+		return party, errors.New("Unable to retrieve party: " + partyId + ". " + err.Error())
+	}
+	if partyBytes == nil {
+		return party, errors.New("Nil value for party: " + partyId)
+	}
+
+	err = json.Unmarshal(partyBytes, &party)
+
+	return party, err
+
+}
+
+func addPartyToLedger(stub shim.ChaincodeStubInterface, party Party) error {
+
+	partyAsBytes, err := json.Marshal(party)
+	if err != nil {
+		return errors.New("Unable to convert party to json string " + string(partyAsBytes))
+	}
+
+	partyKey, err := stub.CreateCompositeKey("party", []string{party.PartyId})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(partyKey, partyAsBytes)
+
+}
+
+//beneficial ownership methods
+//verifyValidShareholders checks an entity's shareholders are distinct, exclude the entity
+//itself and hold the whole of it, and leaves each with its exact share
+func verifyValidShareholders(party Party) error {
+
+	if len(party.Shareholders) == 0 {
+		return nil
+	}
+	if party.Type == partyPerson {
+		return errors.New("Party " + party.PartyId + " is a person and can not have shareholders.")
+	}
+
+	holders := []Attribute{}
+	for i := 0; i < len(party.Shareholders); i++ {
+
+		shareholder := party.Shareholders[i]
+		if strings.TrimSpace(shareholder.PartyId) == "" {
+			return errors.New("Each shareholder needs a party id.")
+		}
+		if shareholder.PartyId == party.PartyId {
+			return errors.New("Party " + party.PartyId + " can not hold a share of itself.")
+		}
+
+		for j := 0; j < i; j++ {
+			if party.Shareholders[j].PartyId == shareholder.PartyId {
+				return errors.New("Shareholder " + shareholder.PartyId + " is listed more than once.")
+			}
+		}
+
+		holders = append(holders, Attribute{Id: shareholder.PartyId, Percent: shareholder.Percent, Share: shareholder.Share})
+
+	}
+
+	err := normalizeShares(holders)
+	if err != nil {
+		return err
+	}
+
+	err = confirmValidPercentage(holders)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(holders); i++ {
+		party.Shareholders[i].Share = holders[i].Share
+		party.Shareholders[i].Percent = holders[i].Percent
+	}
+
+	return nil
+
+}
+
+//lookThroughParty adds the beneficial owners of a party holding the given share. The path holds
+//the entities already passed through, so meeting one of them again is an ownership cycle.
+func lookThroughParty(stub shim.ChaincodeStubInterface, owners []BeneficialOwner, shares map[string]*big.Rat, partyId string, share *big.Rat, path []string) ([]BeneficialOwner, error) {
+
+	path = append(append([]string{}, path...), partyId)
+	if containsString(path[:len(path)-1], partyId) {
+		return nil, errors.New("Ownership cycle found: " + strings.Join(path, " -> ") + ".")
+	}
+
+	party, err := getPartyFromLedger(stub, partyId)
+	if err != nil {
+		return nil, errors.New("Beneficial owner " + partyId + " is not a registered party.")
+	}
+
+	if len(party.Shareholders) == 0 {
+		return addBeneficialShare(owners, shares, BeneficialOwner{PartyId: party.PartyId, LegalName: party.LegalName, Type: party.Type}, share, path), nil
+	}
+
+	for i := 0; i < len(party.Shareholders); i++ {
+
+		holderShare, err := getShare(Attribute{Id: party.Shareholders[i].PartyId, Percent: party.Shareholders[i].Percent, Share: party.Shareholders[i].Share})
+		if err != nil {
+			return nil, err
+		}
+
+		owners, err = lookThroughParty(stub, owners, shares, party.Shareholders[i].PartyId, new(big.Rat).Mul(share, holderShare), path)
+		if err != nil {
+			return nil, err
+		}
+
+	}
+
+	return owners, nil
+
+}
+
+//addBeneficialShare adds a share reached through one path, combining parties reached through
+//several owners or shareholders
+func addBeneficialShare(owners []BeneficialOwner, shares map[string]*big.Rat, owner BeneficialOwner, share *big.Rat, path []string) []BeneficialOwner {
+
+	key := getBeneficialOwnerKey(owner)
+	if _, ok := shares[key]; !ok {
+		shares[key] = new(big.Rat)
+		owner.Paths = [][]string{}
+		owners = append(owners, owner)
+	}
+
+	shares[key].Add(shares[key], share)
+
+	for i := 0; i < len(owners); i++ {
+		if getBeneficialOwnerKey(owners[i]) == key {
+			owners[i].Paths = append(owners[i].Paths, path)
+		}
+	}
+
+	return owners
+
+}
+
+func getBeneficialOwnerKey(owner BeneficialOwner) string {
+
+	if owner.PartyId != "" {
+		return "party:" + owner.PartyId
+	}
+
+	return "ownership:" + owner.OwnershipId
+
+}
+
+func withBeneficialShare(owner BeneficialOwner, share *big.Rat) BeneficialOwner {
+
+	owner.Share = share.RatString()
+	owner.Percent, _ = share.Float64()
+
+	return owner
+
+}
+
+//chain of title methods
+
+//buildChainOfTitle turns the committed versions of a property, oldest first, into its chain of
+//title. Versions that leave the owners unchanged, such as status or detail updates, are not conveyances.
+func buildChainOfTitle(propertyId string, versions []titleVersion, liens []Lien) ChainOfTitle {
+
+	chainOfTitle := ChainOfTitle{}
+	chainOfTitle.PropertyId = propertyId
+	chainOfTitle.Conveyances = []Conveyance{}
+	chainOfTitle.Liens = liens
+	chainOfTitle.Anomalies = []TitleAnomaly{}
+
+	previousOwners := []Attribute{}
+	previousSaleDate := ""
+
+	for i := 0; i < len(versions); i++ {
+
+		version := versions[i]
+
+		if version.IsDelete {
+			chainOfTitle.Anomalies = append(chainOfTitle.Anomalies, TitleAnomaly{TxId: version.TxId, Type: "deleted", Description: "The property record was deleted."})
+			previousOwners = []Attribute{}
+			continue
+		}
+
+		property := version.Property
+		chainOfTitle.ParcelNumber = property.ParcelNumber
+		chainOfTitle.LegalDescription = property.LegalDescription
+		chainOfTitle.Address = property.Address
+
+		if haveSameOwners(previousOwners, property.Owners) {
+			continue
+		}
+
+		if len(property.Owners) == 0 {
+			chainOfTitle.Anomalies = append(chainOfTitle.Anomalies, TitleAnomaly{TxId: version.TxId, Type: "gap", Description: "The property was left without owners."})
+			previousOwners = property.Owners
+			continue
+		}
+
+		conveyance := Conveyance{}
+		conveyance.Sequence = len(chainOfTitle.Conveyances) + 1
+		conveyance.TxId = version.TxId
+		conveyance.Timestamp = version.Timestamp
+		conveyance.SaleDate = property.SaleDate
+		conveyance.SalePrice = property.SalePrice
+		conveyance.TransferType = getTransferType(property.TransferTerms)
+		conveyance.Grantors = previousOwners
+		conveyance.Grantees = property.Owners
+
+		chainOfTitle.Conveyances = append(chainOfTitle.Conveyances, conveyance)
+		chainOfTitle.Anomalies = append(chainOfTitle.Anomalies, getConveyanceAnomalies(conveyance, previousSaleDate)...)
+
+		previousOwners = property.Owners
+		if property.SaleDate != "" {
+			previousSaleDate = property.SaleDate
+		}
+
+	}
+
+	return chainOfTitle
+
+}
+
+func getConveyanceAnomalies(conveyance Conveyance, previousSaleDate string) []TitleAnomaly {
+
+	anomalies := []TitleAnomaly{}
+	addAnomaly := func(anomalyType string, description string) {
+		anomalies = append(anomalies, TitleAnomaly{TxId: conveyance.TxId, Type: anomalyType, Description: description})
+	}
+
+	if conveyance.Sequence > 1 && len(conveyance.Grantors) == 0 {
+		addAnomaly("gap", "The property was conveyed with no recorded grantor.")
+	}
+	if strings.TrimSpace(conveyance.SaleDate) == "" {
+		addAnomaly("missing-sale-date", "The conveyance has no sale date.")
+	} else if previousSaleDate != "" && conveyance.SaleDate < previousSaleDate {
+		addAnomaly("out-of-order", "The sale date " + conveyance.SaleDate + " is earlier than the previous conveyance on " + previousSaleDate + ".")
+	}
+	if conveyance.Sequence > 1 && conveyance.TransferType == transferSale && conveyance.SalePrice <= 0 {
+		addAnomaly("missing-sale-price", "The conveyance has no sale price.")
+	}
+	if err := confirmValidPercentage(conveyance.Grantees); err != nil {
+		addAnomaly("share-total", err.Error())
+	}
+	for i := 0; i < len(conveyance.Grantees); i++ {
+		if strings.TrimSpace(conveyance.Grantees[i].Name) == "" && conveyance.Grantees[i].PartyId == "" {
+			addAnomaly("missing-name", "Grantee " + conveyance.Grantees[i].Id + " has no name.")
+		}
+	}
+
+	return anomalies
+
+}
+
+//haveSameOwners reports whether two owner lists hold the same ownerships with the same shares
+func haveSameOwners(firstOwners []Attribute, secondOwners []Attribute) bool {
+
+	if len(firstOwners) != len(secondOwners) {
+		return false
+	}
+
+	for i := 0; i < len(firstOwners); i++ {
+
+		foundMatch := false
+		for j := 0; j < len(secondOwners); j++ {
+
+			if firstOwners[i].Id != secondOwners[j].Id {
+				continue
+			}
 
+			firstShare, err := getShare(firstOwners[i])
+			if err != nil {
+				return false
+			}
+			secondShare, err := getShare(secondOwners[j])
+			if err != nil {
+				return false
 			}
 
-		}else {
-			err = nil
+			foundMatch = firstShare.Cmp(secondShare) == 0
+			break
+
+		}
+
+		if !foundMatch {
+			return false
 		}
 
 	}
 
-	return err
+	return true
 
 }
 
-func addPropertyToOwnership(stub shim.ChaincodeStubInterface, newOwnersList []Attribute, newProperty Property) error{
+//offer methods
+func hasAcceptanceThreshold(stub shim.ChaincodeStubInterface, approvals []Attribute) (bool, error) {
 
-	var err error
-	var propertyAttribute = Attribute{}
+	thresholdString, err := getConfigFromLedger(stub, "acceptanceThreshold")
+	if err != nil {
+		return false, err
+	}
+	if thresholdString == "" {
+		thresholdString = defaultAcceptanceThreshold
+	}
 
-	for i := 0; i < len(newOwnersList); i++ {
+	threshold, ok := new(big.Rat).SetString(thresholdString)
+	if !ok {
+		return false, errors.New("Invalid acceptance threshold: " + thresholdString)
+	}
 
-		ownershipAsBytes, err := getOwnershipFromLedger(stub, newOwnersList[i].Id)
+	approvedShare := new(big.Rat)
+	for i := 0; i < len(approvals); i++ {
 
-		propertyAttribute.Id = newProperty.PropertyId
-		propertyAttribute.SaleDate = newProperty.SaleDate
-		propertyAttribute.Percent = newOwnersList[i].Percent
-		propertyAttribute.Name = newOwnersList[i].Name
+		share, err := getShare(approvals[i])
+		if err != nil {
+			return false, err
+		}
 
-		ownership := Ownership{}
-		if ownershipAsBytes != nil {
+		approvedShare.Add(approvedShare, share)
 
-			err = json.Unmarshal(ownershipAsBytes, &ownership)
-			if err != nil {
-				return err
-			}
+	}
 
-		} else {
-			err = nil
-		}
+	return approvedShare.Cmp(threshold) >= 0, nil
 
-		ownership.Properties = append(ownership.Properties, propertyAttribute)
+}
 
-		err = addOwnershipToLedger(stub, ownership, newOwnersList[i].Id)
-		if err != nil {
-			return err
-		}
+//an offer is only good against the owners it was made to
+func confirmSellersUnchanged(stub shim.ChaincodeStubInterface, offer Offer) error {
 
+	property, err := getPropertyStruct(stub, offer.PropertyId)
+	if err != nil {
+		return err
 	}
 
-	return err
-
-}
+	if len(property.Owners) != len(offer.Sellers) {
+		return errors.New("The owners of " + offer.PropertyId + " have changed since offer " + offer.OfferId + " was made.")
+	}
 
-func removePropertyFromOwnership(stub shim.ChaincodeStubInterface, oldOwners []Attribute, propertyId string) error{
+	for i := 0; i < len(offer.Sellers); i++ {
 
-	var err error
+		foundMatch := false
+		for j := 0; j < len(property.Owners); j++ {
+			if property.Owners[j].Id == offer.Sellers[i].Id && property.Owners[j].Share == offer.Sellers[i].Share {
+				foundMatch = true
+				break
+			}
+		}
 
-	for i := 0; i < len(oldOwners); i++ {
+		if !foundMatch {
+			return errors.New("The owners of " + offer.PropertyId + " have changed since offer " + offer.OfferId + " was made.")
+		}
 
-		ownershipAsBytes, err := getOwnershipFromLedger(stub, oldOwners[i].Id)
+	}
 
-		ownership := Ownership{}
-		if ownershipAsBytes != nil {
+	return nil
 
-			err = json.Unmarshal(ownershipAsBytes, &ownership)
-			if err != nil {
-				return err
-			}
+}
 
-			ownership.Properties = removePropertyFromOwnershipList(ownership.Properties, propertyId)
+func requireOwnershipController(stub shim.ChaincodeStubInterface, ownershipId string) error {
 
-		}else {
-			err = nil
-		}
+	caller, err := getCallerId(stub)
+	if err != nil {
+		return err
+	}
 
-		err = addOwnershipToLedger(stub, ownership, oldOwners[i].Id)
-		if err != nil {
-			return err
-		}
+	controllerKey, err := stub.CreateCompositeKey("controller", []string{ownershipId})
+	if err != nil {
+		return err
+	}
 
+	controller, err := stub.GetState(controllerKey)
+	if err != nil {
+		return errors.New("Unable to retrieve controller for ownershipId: " + ownershipId + ". " + err.Error())
+	}
+	if string(controller) != caller {
+		return errors.New(caller + " does not control " + ownershipId + ".")
 	}
 
-	return err
+	return nil
 
 }
 
-func removePropertyFromOwnershipList(properties []Attribute, propertyId string) []Attribute{
+func addOfferToLedger(stub shim.ChaincodeStubInterface, offer Offer) error {
 
-	for i, v := range properties {
-		if v.Id == propertyId {
-			properties[i] = properties[len(properties) - 1]
-			properties = properties[: len(properties) - 1]
-		}
+	offerAsBytes, err := json.Marshal(offer)
+	if err != nil {
+		return errors.New("Unable to convert offer to json string " + string(offerAsBytes))
 	}
 
-	return properties
+	offerKey, err := stub.CreateCompositeKey("offer", []string{offer.OfferId})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(offerKey, offerAsBytes)
+
 }
 
-func getOwnershipLists(newOwners []Attribute, oldOwners []Attribute) ([]Attribute, []Attribute, []Attribute){
-	var sameOwners = []Attribute{}
-	var updatedNewOwners = []Attribute{}
-	var updatedOldOwners = []Attribute{}
+func getOfferFromLedger(stub shim.ChaincodeStubInterface, offerId string) (Offer, error) {
 
-	if len(newOwners) >= len(oldOwners) {
-		sameOwners, updatedNewOwners, updatedOldOwners = buildOwnershipLists(newOwners, oldOwners)
-	} else {
-		sameOwners, updatedOldOwners, updatedNewOwners = buildOwnershipLists(oldOwners, newOwners)
+	offer := Offer{}
+
+	offerKey, err := stub.CreateCompositeKey("offer", []string{offerId})
+	if err != nil {
+		return offer, err
 	}
 
-	return sameOwners, updatedNewOwners, updatedOldOwners
+	offerBytes, err := stub.GetState(offerKey)
+	if err != nil {
+		return offer, errors.New("Unable to retrieve offer: " + offerId + ". " + err.Error())
+	}
+	if offerBytes == nil {
+		return offer, errors.New("Nil value for offer: " + offerId)
+	}
+
+	err = json.Unmarshal(offerBytes, &offer)
+
+	return offer, err
 
 }
 
-func buildOwnershipLists(longestOwnerList []Attribute, shortestOwnerList []Attribute) ([]Attribute, []Attribute, []Attribute){
+//config methods
+func getConfigFromLedger(stub shim.ChaincodeStubInterface, name string) (string, error) {
 
-	var sameOwnersList = []Attribute{}
-	var updatedLongestOwnerList = []Attribute{}
-	var updatedShortestOwnerList = []Attribute{}
+	configKey, err := stub.CreateCompositeKey("config", []string{name})
+	if err != nil {
+		return "", err
+	}
 
-	sameOwnersList, updatedLongestOwnerList = getLongestAndSameOwnershipLists(longestOwnerList, shortestOwnerList)
+	configBytes, err := stub.GetState(configKey)
+	if err != nil {
+		return "", errors.New("Unable to retrieve config: " + name + ". " + err.Error())
+	}
 
-	updatedShortestOwnerList = getShortestOwnershipList(shortestOwnerList, sameOwnersList)
+	return string(configBytes), nil
 
-	return sameOwnersList, updatedLongestOwnerList, updatedShortestOwnerList
+}
+
+func addConfigToLedger(stub shim.ChaincodeStubInterface, name string, value string) error {
+
+	configKey, err := stub.CreateCompositeKey("config", []string{name})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(configKey, []byte(value))
 
 }
 
-func getLongestAndSameOwnershipLists(longestOwnersList []Attribute, shortestOwnersList []Attribute) ([]Attribute, []Attribute){
+//property registry methods
+func updatePropertyDetailsOnLedger(stub shim.ChaincodeStubInterface, property *Property, details PropertyDetails, propertyId string) error {
 
-	var sameOwners = []Attribute{}
-	var updatedLongestOwnersList = []Attribute{}
+	err := verifyValidPropertyDetails(details)
+	if err != nil {
+		return err
+	}
 
-	for i := 0; i < len(longestOwnersList); i++ {
-		var foundMatch = false
+	//parcel numbers identify a single property
+	if property.ParcelNumber != details.ParcelNumber {
 
-		for j := 0; j< len(shortestOwnersList); j++ {
+		parcelKey, err := stub.CreateCompositeKey("parcel", []string{details.ParcelNumber})
+		if err != nil {
+			return err
+		}
 
-			if longestOwnersList[i].Id == shortestOwnersList[j].Id {
+		parcelPropertyId, err := stub.GetState(parcelKey)
+		if err != nil {
+			return err
+		}
+		if parcelPropertyId != nil {
+			return errors.New("Parcel number " + details.ParcelNumber + " is already registered to " + string(parcelPropertyId) + ".")
+		}
 
-				sameOwners = append(sameOwners, longestOwnersList[i])
-				foundMatch = true
-				break
+		err = stub.PutState(parcelKey, []byte(propertyId))
+		if err != nil {
+			return err
+		}
 
-			} else {
-				foundMatch = false
+		if property.ParcelNumber != "" {
+
+			oldParcelKey, err := stub.CreateCompositeKey("parcel", []string{property.ParcelNumber})
+			if err != nil {
+				return err
 			}
 
-		}
+			err = stub.DelState(oldParcelKey)
+			if err != nil {
+				return err
+			}
 
-		if !foundMatch{
-			updatedLongestOwnersList = append(updatedLongestOwnersList, longestOwnersList[i])
 		}
 
 	}
 
-	return sameOwners, updatedLongestOwnersList
+	property.ParcelNumber = details.ParcelNumber
+	property.LegalDescription = details.LegalDescription
+	property.Address = details.Address
+	property.LandArea = details.LandArea
+	property.Zoning = details.Zoning
+
+	return addPropertyToLedger(stub, *property, propertyId)
 
 }
 
-func getShortestOwnershipList(shortestOwnersList []Attribute, sameOwnersList []Attribute) ([]Attribute){
+func verifyValidPropertyDetails(details PropertyDetails) error {
 
-	var updatedShortestOwnersList = []Attribute{}
+	var err error
 
-	for k := 0; k <len(shortestOwnersList); k++ {
+	if strings.TrimSpace(details.ParcelNumber) == "" {
+		err = errors.New("A parcel number is required.")
+		return err
+	}
+	if strings.TrimSpace(details.LegalDescription) == "" {
+		err = errors.New("A legal description is required.")
+		return err
+	}
+	if strings.TrimSpace(details.Address) == "" {
+		err = errors.New("An address is required.")
+		return err
+	}
+	if details.LandArea <= 0 {
+		err = errors.New("The land area must be greater than 0.")
+		return err
+	}
+	if strings.TrimSpace(details.Zoning) == "" {
+		err = errors.New("A zoning designation is required.")
+	}
 
-		var foundMatch = false
+	return err
 
-		for m:= 0; m < len(sameOwnersList); m++ {
+}
 
-			if shortestOwnersList[k].Id == sameOwnersList[m].Id {
-				foundMatch = true
-				break
-			} else {
-				foundMatch = false
-			}
+//registered details and the listing status are only changed through their own methods,
+//so a sale keeps them
+func keepRegisteredDetails(newProperty Property, originalPropertyBytes []byte) (Property, error) {
 
-		}
+	originalProperty := Property{}
+	if originalPropertyBytes != nil {
 
-		if !foundMatch {
-			updatedShortestOwnersList = append(updatedShortestOwnersList, shortestOwnersList[k])
+		err := json.Unmarshal(originalPropertyBytes, &originalProperty)
+		if err != nil {
+			err = errors.New("Unable to create originalPropertyBytes: " + string(originalPropertyBytes) + ". " + err.Error())
+			return newProperty, err
 		}
 
 	}
 
-	return updatedShortestOwnersList
+	newProperty.ParcelNumber = originalProperty.ParcelNumber
+	newProperty.LegalDescription = originalProperty.LegalDescription
+	newProperty.Address = originalProperty.Address
+	newProperty.LandArea = originalProperty.LandArea
+	newProperty.Zoning = originalProperty.Zoning
+	newProperty.Status = originalProperty.Status
+	newProperty.StatusDate = originalProperty.StatusDate
+	newProperty.Parents = originalProperty.Parents
+	newProperty.Children = originalProperty.Children
+
+	return newProperty, nil
 
 }
 
-func verifyValidProperty(property Property) (error){
+//identity methods
+//getCallerId identifies the submitter as <MSP ID>::<certificate common name>.
+//It is a variable so the tests can stand in for the creator the MockStub lacks.
+var getCallerId = func(stub shim.ChaincodeStubInterface) (string, error) {
 
-	var err error
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", errors.New("Unable to get the caller's MSP ID. " + err.Error())
+	}
 
-	if strings.TrimSpace(property.SaleDate) == "" {
-		err = errors.New("A sale date is required.")
-		return err
+	cert, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		return "", errors.New("Unable to get the caller's certificate. " + err.Error())
 	}
-	if property.SalePrice < 1 {
-		err = errors.New("The sale price must be greater than 0.")
-		return err
+	if cert == nil {
+		return "", errors.New("The caller has no X.509 certificate.")
 	}
-	if len(property.Owners) < 1 {
-		err = errors.New("At least one owner is required.")
+
+	return mspId + "::" + cert.Subject.CommonName, nil
+
+}
+
+func requireRole(stub shim.ChaincodeStubInterface, role string) (string, error) {
+
+	caller, err := getCallerId(stub)
+	if err != nil {
+		return caller, err
 	}
 
-	return err
+	hasRole, err := callerHasRole(stub, role, caller)
+	if err != nil {
+		return caller, err
+	}
+	if !hasRole {
+		err = errors.New(caller + " does not have the " + role + " role.")
+	}
+
+	return caller, err
+
 }
 
-func confirmValidPercentage(buyers []Attribute) error{
+func callerHasRole(stub shim.ChaincodeStubInterface, role string, caller string) (bool, error) {
 
-	var totalPercentage float64
-	var err error
+	roleKey, err := stub.CreateCompositeKey("role", []string{role, caller})
+	if err != nil {
+		return false, err
+	}
 
-	for i := 0; i < len(buyers); i++ {
-		totalPercentage += buyers[i].Percent
+	roleBytes, err := stub.GetState(roleKey)
+	if err != nil {
+		return false, errors.New("Unable to retrieve role: " + role + ". " + err.Error())
 	}
 
-	if totalPercentage != 1 {
-		totalPercentageString := fmt.Sprint(totalPercentage)
-		err = errors.New("Total Percentage can not be greater than or less than 1. Your total percentage =" + totalPercentageString)
+	return roleBytes != nil, nil
+
+}
+
+func addRoleToLedger(stub shim.ChaincodeStubInterface, role string, identity string) error {
+
+	if strings.TrimSpace(identity) == "" || strings.TrimSpace(role) == "" {
+		return errors.New("An identity and a role are required.")
 	}
 
-	return err
+	roleKey, err := stub.CreateCompositeKey("role", []string{role, identity})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(roleKey, []byte(identity))
 
 }
 
 //helper methods
+func containsString(values []string, value string) bool {
+
+	for i := 0; i < len(values); i++ {
+		if values[i] == value {
+			return true
+		}
+	}
+
+	return false
+
+}
+
 func addOwnershipToLedger(stub shim.ChaincodeStubInterface, ownership Ownership, ownershipId string) error{
 
 	updatedOwnershipAsBytes, err := getOwnershipAsBytes(ownership)
@@ -729,7 +6576,7 @@ func getOwnershipPropertiesAsBytes(stub shim.ChaincodeStubInterface, ownershipId
 		return ownershipBytes, err
 	}
 
-	ownershipProperties := getOwnershipPropertiesIdValues(ownership.Properties)
+	ownershipProperties := getOwnershipPropertiesIdValues(withOwnershipPartyName(stub, ownership).Properties)
 
 	ownershipPropertiesAsBytes, err := json.Marshal(ownershipProperties)
 	if err != nil{
@@ -787,6 +6634,24 @@ func getPropertyFromLedger(stub shim.ChaincodeStubInterface, propertyId string)
 
 }
 
+func getPropertyStruct(stub shim.ChaincodeStubInterface, propertyId string) (Property, error){
+
+	property := Property{}
+
+	propertyBytes, err := getPropertyFromLedger(stub, propertyId)
+	if err != nil {
+		return property, err
+	}
+
+	err = json.Unmarshal(propertyBytes, &property)
+	if err != nil {
+		err = errors.New("Unable to convert property bytes to Property structure. " + err.Error())
+	}
+
+	return property, err
+
+}
+
 func getPropertyAsBytes(property Property) ([]byte, error){
 
 	var propertyBytes []byte
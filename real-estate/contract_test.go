@@ -25,13 +25,66 @@ import (
 	"strings"
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 const getOwnership = "getOwnership"
 const propertyTransaction = "propertyTransaction"
 const getProperty = "getProperty"
+const registerProperty = "registerProperty"
+const updatePropertyDetails = "updatePropertyDetails"
+const assignRole = "assignRole"
+const createOffer = "createOffer"
+const acceptOffer = "acceptOffer"
+const closeSale = "closeSale"
+const assignOwnershipController = "assignOwnershipController"
+const setAcceptanceThreshold = "setAcceptanceThreshold"
+const listProperty = "listProperty"
+const withdrawListing = "withdrawListing"
+const markUnderContract = "markUnderContract"
+const recordLien = "recordLien"
+const releaseLien = "releaseLien"
+const getEncumbrances = "getEncumbrances"
+const getSettlement = "getSettlement"
+const migrateOwnershipShares = "migrateOwnershipShares"
+const getPropertyAsOf = "getPropertyAsOf"
+const conveyInterest = "conveyInterest"
+const setBeneficiaries = "setBeneficiaries"
+const executeEstateTransfer = "executeEstateTransfer"
+const splitProperty = "splitProperty"
+const mergeProperties = "mergeProperties"
+const getPropertyLineage = "getPropertyLineage"
+const createLease = "createLease"
+const getActivePropertyLeases = "getActivePropertyLeases"
+const getActiveTenantLeases = "getActiveTenantLeases"
+const recordRent = "recordRent"
+const getOwnerIncome = "getOwnerIncome"
+const recordAssessment = "recordAssessment"
+const recordTaxPayment = "recordTaxPayment"
+const applyTaxLiens = "applyTaxLiens"
+const setTaxDelinquencyDays = "setTaxDelinquencyDays"
+const getTaxDelinquency = "getTaxDelinquency"
+const getDelinquentProperties = "getDelinquentProperties"
+const attachDocument = "attachDocument"
+const verifyDocument = "verifyDocument"
+const registerParty = "registerParty"
+const updateParty = "updateParty"
+const setKycStatus = "setKycStatus"
+const getParty = "getParty"
+const setShareholders = "setShareholders"
+const getBeneficialOwners = "getBeneficialOwners"
 const ownership_1 = "ownership_1"
 const property_1 = "property_1"
+const property_2 = "property_2"
+const admin = "Org1MSP::admin"
+const registrar = "Org1MSP::registrar"
+const buyer = "Org1MSP::buyer"
+const executor = "Org1MSP::executor"
+const tenant = "Org1MSP::tenant"
+const assessor = "Org1MSP::assessor"
+const compliance = "Org1MSP::compliance"
+const seller2 = "Org1MSP::seller2"
+const seller3 = "Org1MSP::seller3"
 const dateString = `"2017-06-28T21:57:16"`
 const emptyOwnershipPropertyJson = `{"properties":[]}`
 const emptyPropertyJson = `{"saleDate":"","salePrice":0,"owners":[]}`
@@ -45,6 +98,38 @@ const createPropertyTransactionMissingSaleDateError = "A sale date is required."
 const createPropertyTransactionGreaterThanZeroSalePriceError = "The sale price must be greater than 0"
 const createPropertyTransactionTotalPercentageOfOneError = "Total Percentage can not be greater than or less than 1. Your total percentage ="
 const createPropertyTransactionNoOwnersError = "At least one owner is required."
+const missingRoleError = "does not have the"
+const registerPropertyMissingParcelNumberError = "A parcel number is required."
+const registerPropertyLandAreaError = "The land area must be greater than 0."
+const registerPropertyDuplicateParcelError = "is already registered to"
+const createPropertyTransactionShareDisagreesError = "do not agree."
+const closeSaleNotAcceptedError = "can not be closed."
+const acceptOfferNotControllerError = "does not control"
+const invalidStatusTransitionError = "and can not become"
+const notPropertyOwnerError = "is not an owner of"
+const unreleasedLienError = "has unreleased liens"
+const duplicateLienError = "is already recorded against"
+const reservedLienIdError = "are reserved for tax liens"
+const taxLienCollisionError = "can not be used for the"
+const invalidTimestampError = "Invalid timestamp"
+const conveyMoreThanHeldError = "and can not convey"
+const noBeneficiariesError = "No beneficiaries are designated"
+const retiredPropertyError = "was retired by a split or merge"
+const mergeDifferentOwnersError = "have different owners"
+const overlappingLeaseError = "already covers"
+const minorityOwnerError = "needs a majority share"
+const documentUploaderError = "can not attach documents"
+const unverifiedPartyError = "is not KYC verified"
+const unknownPartyError = "refers to unknown party"
+const missingPartyIdError = "needs a party id"
+const ownershipBoundError = "is bound to party"
+const survivorshipError = "passes to the surviving owners"
+const jointTenantConveyanceError = "can only convey its whole interest"
+const communityPropertyConveyanceError = "can only be conveyed by both spouses"
+const ownershipCycleError = "Ownership cycle found"
+const personShareholdersError = "can not have shareholders"
+const deedHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+const deathCertificateHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
 
 func TestGetOwnershipMissingOwnership(t *testing.T){
 
@@ -71,7 +156,7 @@ func TestGetOwnershipExtraArgs(t *testing.T){
 
 func TestOwnershipCreatedDuringPropertyTransaction(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithRole(registrarRole, registrar)
 
 	var property = Property{}
 
@@ -86,153 +171,1697 @@ func TestOwnershipCreatedDuringPropertyTransaction(t *testing.T){
 
 	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
 
-	ownershipProperty := getAttributesAsString([]Attribute{getValidOwnersProperty1()[0]})
+	expectedProperty := getValidOwnersProperty1()[0]
+	expectedProperty.Name = "Test Party 3"
+	ownershipProperty := getAttributesAsString([]Attribute{expectedProperty})
+
+	checkGetOwnership(t, stub, ownershipInputList[0].Id, ownershipProperty)
+
+}
+
+func TestPropertyTransaction(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	property, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+
+	checkPropertyTransaction(t,stub, property.PropertyId, propertyString)
+	checkPropertyState(t, stub, property, propertyString)
+
+}
+
+func TestPropertyTransactionUnownedNotRegistrar(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+	checkRegisterProperty(t, stub, property_1, getValidPropertyDetailsString())
+
+	setCaller(buyer)
+	_, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+	invalidArgs := getFourArgs(propertyTransaction, property_1, propertyString)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, missingRoleError, invalidArgs, propertyString)
+
+	property := Property{}
+	json.Unmarshal(stub.State[property_1], &property)
+	if len(property.Owners) != 0 {
+		fmt.Println("Unowned property was taken without a registrar:", string(stub.State[property_1]))
+		t.FailNow()
+	}
+
+}
+
+func TestPropertyTransactionExtraArgs(t *testing.T) {
+
+	stub := getStub()
+
+	invalidArgument := "invalidArgument"
+	_, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, propertyString)
+	invalidArgs = append(invalidArgs, []byte(invalidArgument))
+
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + ", " + string(invalidArgs[2]) + ", " + string(invalidArgs[3]) + ", " + string(invalidArgs[4]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, incorrectArgCountError, invalidArgs, propertyString)
+
+}
+
+func TestPropertyTransactionStringAsSalePrice(t *testing.T) {
+
+	stub := getStub()
+
+	validJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":"1000","owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownerhip_2","percentage":0.55}]}`
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, validJson)
+
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionUnmarshalWrongTypeError, invalidArgs, validJson )
+
+}
+
+func TestPropertyTransactionMissingSaleDate(t *testing.T) {
+
+	stub := getStub()
+
+	missingSaleDateJson := `{"salePrice":1000,"owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownership_2","percentage":0.55}]}`
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, missingSaleDateJson)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionMissingSaleDateError, invalidArgs, missingSaleDateJson)
+
+}
+
+func TestPropertyTransactionNegativeSalePrice(t *testing.T) {
+
+	stub := getStub()
+
+	negativeSalePriceJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":-1,"owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownerhip_2","percentage":0.55}]}`
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, negativeSalePriceJson)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionGreaterThanZeroSalePriceError, invalidArgs, negativeSalePriceJson)
+
+}
+
+func TestPropertyTransactionNoOwners(t *testing.T) {
+
+	stub := getStub()
+
+	noOwnersJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[]}`
+	
+	invalidArgs := getFourArgs(propertyTransaction, property_1, noOwnersJson)
+	message:= " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionNoOwnersError, invalidArgs, noOwnersJson)
+
+}
+
+func TestPropertyTransactionTooLowTotalOwnerPercentage(t *testing.T) {
+
+	stub := getStub()
+
+	tooLowOwnerPercentage := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownerhip_2","percentage":0.50}]}`
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, tooLowOwnerPercentage)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionTotalPercentageOfOneError, invalidArgs, tooLowOwnerPercentage)
+
+}
+
+func TestPropertyTransactionTooHighTotalOwnerPercentage(t *testing.T) {
+
+	stub := getStub()
+
+	tooHighOwnerPercentage := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownerhip_2","percentage":0.70}]}`
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, tooHighOwnerPercentage)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionTotalPercentageOfOneError, invalidArgs, tooHighOwnerPercentage)
+
+}
+
+func TestPropertyTransactionThirds(t *testing.T) {
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	thirdsJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_1","partyId":"party_1","share":"1/3"},{"id":"ownership_2","partyId":"party_2","share":"1/3"},{"id":"ownership_3","partyId":"party_3","share":"1/3"}]}`
+
+	checkPropertyTransaction(t, stub, property_1, thirdsJson)
+
+}
+
+func TestPropertyTransactionDecimalPercentages(t *testing.T) {
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	decimalJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_1","partyId":"party_1","percent":0.1},{"id":"ownership_2","partyId":"party_2","percent":0.2},{"id":"ownership_3","partyId":"party_3","percent":0.7}]}`
+
+	checkPropertyTransaction(t, stub, property_1, decimalJson)
+
+	property := Property{}
+	json.Unmarshal(stub.State[property_1], &property)
+	if property.Owners[0].Share != "1/10" || property.Owners[1].Share != "1/5" || property.Owners[2].Share != "7/10" {
+		fmt.Println("Percentages were not stored as exact shares:", string(stub.State[property_1]))
+		t.FailNow()
+	}
+
+}
+
+func TestPropertyTransactionShareDisagreesWithPercent(t *testing.T) {
+
+	stub := getStub()
+
+	disagreeingJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_1","share":"1/3","percent":0.5},{"id":"ownership_2","share":"2/3"}]}`
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, disagreeingJson)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionShareDisagreesError, invalidArgs, disagreeingJson)
+
+}
+
+func TestGetProperty(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	property, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+
+	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
+
+	//owner names come from the party registry
+	owners := getValidOwners()
+	owners[0].Name = "Test Party 3"
+	owners[1].Name = "Test Party 2"
+	_, namedPropertyString := getTestProperty(property_1, dateString, 1000, owners)
+
+	checkGetProperty(t, stub, property, namedPropertyString)
+
+}
+
+func TestGetPropertyExtraArgs(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	property, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+
+	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
+
+	invalidArgs := getFourArgs(getProperty, property.PropertyId, propertyString)
+
+	message:= " | " + getProperty + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, getProperty, message, incorrectArgCountError, invalidArgs, propertyString)
+
+}
+
+func TestGetPropertyMissingProperty(t *testing.T){
+
+	stub := getStub()
+
+	invalidArgs := getThreeArgs(getProperty, property_1)
+	message := " | " + getProperty + " with args: {" + property_1 + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, getProperty, message, getPropertyNilError, invalidArgs, emptyPropertyJson)
+
+}
+
+func TestRegisterProperty(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	property, propertyString := getTestRegisteredProperty(registerProperty, property_1, getValidPropertyDetails())
+
+	checkRegisterProperty(t, stub, property_1, getValidPropertyDetailsString())
+	checkGetProperty(t, stub, property, propertyString)
+
+}
+
+func TestRegisterPropertyNotRegistrar(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+	setCaller(buyer)
+
+	invalidArgs := getFourArgs(registerProperty, property_1, getValidPropertyDetailsString())
+	message := " | " + registerProperty + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, registerProperty, message, missingRoleError, invalidArgs, string(invalidArgs[3]))
+
+}
+
+func TestRegisterPropertyMissingParcelNumber(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	details := getValidPropertyDetails()
+	details.ParcelNumber = " "
+	detailsAsBytes, _ := json.Marshal(details)
+
+	invalidArgs := getFourArgs(registerProperty, property_1, string(detailsAsBytes))
+	message := " | " + registerProperty + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, registerProperty, message, registerPropertyMissingParcelNumberError, invalidArgs, string(invalidArgs[3]))
+
+}
+
+func TestRegisterPropertyZeroLandArea(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	details := getValidPropertyDetails()
+	details.LandArea = 0
+	detailsAsBytes, _ := json.Marshal(details)
+
+	invalidArgs := getFourArgs(registerProperty, property_1, string(detailsAsBytes))
+	message := " | " + registerProperty + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, registerProperty, message, registerPropertyLandAreaError, invalidArgs, string(invalidArgs[3]))
+
+}
+
+func TestRegisterPropertyDuplicateParcelNumber(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	checkRegisterProperty(t, stub, property_1, getValidPropertyDetailsString())
+
+	invalidArgs := getFourArgs(registerProperty, property_2, getValidPropertyDetailsString())
+	message := " | " + registerProperty + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, registerProperty, message, registerPropertyDuplicateParcelError, invalidArgs, string(invalidArgs[3]))
+
+}
+
+func TestUpdatePropertyDetails(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	checkRegisterProperty(t, stub, property_1, getValidPropertyDetailsString())
+
+	details := getValidPropertyDetails()
+	details.Zoning = "C-1"
+	detailsAsBytes, _ := json.Marshal(details)
+
+	updateArgs := getFourArgs(updatePropertyDetails, property_1, string(detailsAsBytes))
+	res := stub.MockInvoke(updatePropertyDetails, updateArgs)
+	if res.Status != shim.OK {
+		fmt.Println(updatePropertyDetails, "failed. [res.Message=" + res.Message + "]")
+		t.FailNow()
+	}
+
+	property, propertyString := getTestRegisteredProperty(updatePropertyDetails, property_1, details)
+	checkGetProperty(t, stub, property, propertyString)
+
+}
+
+func TestPropertyTransactionKeepsRegisteredDetails(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	checkRegisterProperty(t, stub, property_1, getValidPropertyDetailsString())
+
+	property, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
+
+	property.ParcelNumber = getValidPropertyDetails().ParcelNumber
+	property.LegalDescription = getValidPropertyDetails().LegalDescription
+	property.Address = getValidPropertyDetails().Address
+	property.LandArea = getValidPropertyDetails().LandArea
+	property.Zoning = getValidPropertyDetails().Zoning
+	propertyAsBytes, _ := getPropertyAsBytes(property)
+
+	checkPropertyState(t, stub, property, string(propertyAsBytes))
+
+}
+
+func TestCloseSaleAfterAllOwnersAccept(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(buyer)
+	checkInvoke(t, stub, createOffer, getFourArgs(createOffer, property_1, getBuyerOfferString()))
+
+	setCaller(seller3)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_3"))
+
+	setCaller(seller2)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_2"))
+
+	setCaller(buyer)
+	checkInvoke(t, stub, closeSale, getThreeArgs(closeSale, createOffer))
+
+	property := Property{}
+	json.Unmarshal(stub.State[property_1], &property)
+	if len(property.Owners) != 1 || property.Owners[0].Id != "ownership_4" || property.SalePrice != 2000 {
+		fmt.Println("Property was not sold to the buyer:", string(stub.State[property_1]))
+		t.FailNow()
+	}
+
+}
+
+func TestCloseSaleWithoutAcceptance(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(buyer)
+	checkInvoke(t, stub, createOffer, getFourArgs(createOffer, property_1, getBuyerOfferString()))
+
+	setCaller(seller3)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_3"))
+
+	setCaller(buyer)
+	invalidArgs := getThreeArgs(closeSale, createOffer)
+	message := " | " + closeSale + " with args: {" + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, closeSale, message, closeSaleNotAcceptedError, invalidArgs, createOffer)
+
+}
+
+func TestCloseSaleWithAcceptanceThreshold(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(admin)
+	checkInvoke(t, stub, setAcceptanceThreshold, getThreeArgs(setAcceptanceThreshold, "1/2"))
+
+	setCaller(buyer)
+	checkInvoke(t, stub, createOffer, getFourArgs(createOffer, property_1, getBuyerOfferString()))
+
+	setCaller(seller2)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_2"))
+
+	setCaller(buyer)
+	checkInvoke(t, stub, closeSale, getThreeArgs(closeSale, createOffer))
+
+}
+
+func TestAcceptOfferNotController(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(buyer)
+	checkInvoke(t, stub, createOffer, getFourArgs(createOffer, property_1, getBuyerOfferString()))
+
+	invalidArgs := getFourArgs(acceptOffer, createOffer, "ownership_3")
+	message := " | " + acceptOffer + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, acceptOffer, message, acceptOfferNotControllerError, invalidArgs, createOffer)
+
+}
+
+func TestPropertyTransactionOwnedPropertyNotRegistrar(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(buyer)
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, getBuyerOfferString())
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, missingRoleError, invalidArgs, getBuyerOfferString())
+
+}
+
+func TestListingLifecycle(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+	checkInvoke(t, stub, listProperty, getFourArgs(listProperty, property_1, "ownership_3"))
+	checkPropertyStatus(t, stub, property_1, statusListed)
+
+	checkInvoke(t, stub, withdrawListing, getFourArgs(withdrawListing, property_1, "ownership_3"))
+	checkPropertyStatus(t, stub, property_1, statusWithdrawn)
+
+	checkInvoke(t, stub, listProperty, getFourArgs(listProperty, property_1, "ownership_3"))
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+	checkPropertyStatus(t, stub, property_1, statusUnderContract)
+
+	setCaller(buyer)
+	checkInvoke(t, stub, createOffer, getFourArgs(createOffer, property_1, getBuyerOfferString()))
+	setCaller(seller3)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_3"))
+	setCaller(seller2)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_2"))
+	setCaller(buyer)
+	checkInvoke(t, stub, closeSale, getThreeArgs(closeSale, createOffer))
+
+	checkPropertyStatus(t, stub, property_1, statusSold)
+
+}
+
+func TestWithdrawOffMarketProperty(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+
+	invalidArgs := getFourArgs(withdrawListing, property_1, "ownership_3")
+	message := " | " + withdrawListing + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, withdrawListing, message, invalidStatusTransitionError, invalidArgs, property_1)
+
+}
+
+func TestCloseSaleWithdrawnProperty(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+	checkInvoke(t, stub, listProperty, getFourArgs(listProperty, property_1, "ownership_3"))
+	checkInvoke(t, stub, withdrawListing, getFourArgs(withdrawListing, property_1, "ownership_3"))
+
+	setCaller(buyer)
+	checkInvoke(t, stub, createOffer, getFourArgs(createOffer, property_1, getBuyerOfferString()))
+	setCaller(seller3)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_3"))
+	setCaller(seller2)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_2"))
+
+	setCaller(buyer)
+	invalidArgs := getThreeArgs(closeSale, createOffer)
+	message := " | " + closeSale + " with args: {" + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, closeSale, message, invalidStatusTransitionError, invalidArgs, createOffer)
+
+}
+
+func TestListPropertyNotOwner(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+
+	invalidArgs := getFourArgs(listProperty, property_1, "ownership_4")
+	message := " | " + listProperty + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, listProperty, message, notPropertyOwnerError, invalidArgs, property_1)
+
+}
+
+func TestLienBlocksSaleWithoutPayoff(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+
+	setCaller(seller3)
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+
+	setCaller(registrar)
+	invalidArgs := getFourArgs(propertyTransaction, property_1, getBuyerOfferString())
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, unreleasedLienError, invalidArgs, getBuyerOfferString())
+
+}
+
+func TestLienPaidOffAtClosing(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+
+	setCaller(buyer)
+	offerString := `{"saleDate":"2018-01-15T10:00:00","salePrice":2000,"owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}],"lienPayoffs":["lien_1"]}`
+	checkInvoke(t, stub, createOffer, getFourArgs(createOffer, property_1, offerString))
+	setCaller(seller3)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_3"))
+	setCaller(seller2)
+	checkInvoke(t, stub, acceptOffer, getFourArgs(acceptOffer, createOffer, "ownership_2"))
+	setCaller(buyer)
+	checkInvoke(t, stub, closeSale, getThreeArgs(closeSale, createOffer))
+
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 1 || !liens[0].Released || liens[0].ReleaseTxId != closeSale {
+		fmt.Println("Lien was not released at closing:", liens)
+		t.FailNow()
+	}
+
+}
+
+func TestReleasedLienAllowsSale(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_2", 2)))
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+	checkInvoke(t, stub, releaseLien, getFourArgs(releaseLien, property_1, "lien_1"))
+	checkInvoke(t, stub, releaseLien, getFourArgs(releaseLien, property_1, "lien_2"))
+
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 2 || liens[0].LienId != "lien_1" || liens[1].LienId != "lien_2" {
+		fmt.Println("Liens were not listed in priority order:", liens)
+		t.FailNow()
+	}
+
+	setCaller(seller3)
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+
+	setCaller(registrar)
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, getBuyerOfferString()))
+
+}
+
+func TestRecordLienNotRegistrar(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(buyer)
+
+	invalidArgs := getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1))
+	message := " | " + recordLien + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, recordLien, message, missingRoleError, invalidArgs, property_1)
+
+}
+
+func TestRecordLienDuplicate(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+
+	invalidArgs := getFourArgs(recordLien, property_1, getTestLienString("lien_1", 2))
+	message := " | " + recordLien + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, recordLien, message, duplicateLienError, invalidArgs, property_1)
+
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 1 || liens[0].Priority != 1 {
+		fmt.Println("Recorded lien was overwritten:", liens)
+		t.FailNow()
+	}
+
+}
+
+func TestSettlementPaysLiensBeforeSellers(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_2", 2)))
+
+	setCaller(seller3)
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+
+	setCaller(registrar)
+	saleString := `{"saleDate":"2018-01-15T10:00:00","salePrice":2000,"owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}],"lienPayoffs":["lien_2","lien_1"]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, saleString))
+
+	res := stub.MockInvoke(getSettlement, getFourArgs(getSettlement, property_1, propertyTransaction))
+	if res.Status != shim.OK {
+		fmt.Println(" | " + getSettlement + " failed. [res.Message=" + res.Message + "]")
+		t.FailNow()
+	}
+
+	settlement := Settlement{}
+	json.Unmarshal(res.Payload, &settlement)
+
+	expectedLienPayoffs := []Payout{{Id: "lien_1", Amount: 500}, {Id: "lien_2", Amount: 500}}
+	expectedSellerProceeds := []Payout{{Id: "ownership_3", Amount: 450}, {Id: "ownership_2", Amount: 550}}
+	if fmt.Sprint(settlement.LienPayoffs) != fmt.Sprint(expectedLienPayoffs) || fmt.Sprint(settlement.SellerProceeds) != fmt.Sprint(expectedSellerProceeds) || settlement.Remainder != 0 {
+		fmt.Println("Unexpected settlement:", string(res.Payload))
+		t.FailNow()
+	}
+
+	liens := checkGetEncumbrances(t, stub, property_1)
+	for i := 0; i < len(liens); i++ {
+		if !liens[i].Satisfied || !liens[i].Released {
+			fmt.Println("Lien was not satisfied at closing:", liens[i])
+			t.FailNow()
+		}
+	}
+
+}
+
+func TestSettlementSalePriceDoesNotCoverLiens(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_2", 2)))
+
+	setCaller(seller3)
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+
+	setCaller(registrar)
+	saleString := `{"saleDate":"2018-01-15T10:00:00","salePrice":400,"owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}],"lienPayoffs":["lien_1","lien_2"]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, saleString))
+
+	res := stub.MockInvoke(getSettlement, getFourArgs(getSettlement, property_1, propertyTransaction))
+	settlement := Settlement{}
+	json.Unmarshal(res.Payload, &settlement)
+
+	expectedLienPayoffs := []Payout{{Id: "lien_1", Amount: 400}, {Id: "lien_2", Amount: 0}}
+	expectedShortfalls := []Payout{{Id: "lien_1", Amount: 100}, {Id: "lien_2", Amount: 500}}
+	if fmt.Sprint(settlement.LienPayoffs) != fmt.Sprint(expectedLienPayoffs) || fmt.Sprint(settlement.Shortfalls) != fmt.Sprint(expectedShortfalls) || settlement.Remainder != 0 {
+		fmt.Println("Unexpected settlement:", string(res.Payload))
+		t.FailNow()
+	}
+
+	//the uncovered liens stay on the property for what is still owed
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 2 || liens[0].Released || liens[0].Satisfied || liens[0].Amount != 100 || liens[1].Released || liens[1].Amount != 500 {
+		fmt.Println("Unexpected liens after a short sale:", liens)
+		t.FailNow()
+	}
+
+}
+
+func TestChainOfTitle(t *testing.T){
+
+	registered, _ := getTestRegisteredProperty("tx_1", property_1, getValidPropertyDetails())
+
+	firstSale := registered
+	firstSale.SaleDate = "2017-06-28T21:57:16"
+	firstSale.SalePrice = 1000
+	firstSale.Owners = []Attribute{{Id: "ownership_1", Name: "Ann", Percent: 1, Share: "1"}}
+
+	listed := firstSale
+	listed.Status = statusListed
+
+	emptied := firstSale
+	emptied.Owners = []Attribute{}
+
+	secondSale := firstSale
+	secondSale.SaleDate = "2016-01-01T00:00:00"
+	secondSale.SalePrice = 0
+	secondSale.Owners = []Attribute{{Id: "ownership_2", Name: "Bob", Percent: 0.5, Share: "1/2"}, {Id: "ownership_3", Percent: 0.5, Share: "1/2"}}
+
+	versions := []titleVersion{
+		{TxId: "tx_1", Property: registered},
+		{TxId: "tx_2", Property: firstSale},
+		{TxId: "tx_3", Property: listed},
+		{TxId: "tx_4", Property: emptied},
+		{TxId: "tx_5", Property: secondSale},
+	}
+	liens := []Lien{{LienId: "lien_1", PropertyId: property_1, Holder: "First Bank", Amount: 500, Priority: 1}}
+
+	chainOfTitle := buildChainOfTitle(property_1, versions, liens)
+
+	if len(chainOfTitle.Conveyances) != 2 || chainOfTitle.Conveyances[0].TxId != "tx_2" || chainOfTitle.Conveyances[1].TxId != "tx_5" {
+		fmt.Println("Unexpected conveyances:", chainOfTitle.Conveyances)
+		t.FailNow()
+	}
+	if len(chainOfTitle.Conveyances[0].Grantors) != 0 || len(chainOfTitle.Conveyances[1].Grantees) != 2 {
+		fmt.Println("Unexpected grantors or grantees:", chainOfTitle.Conveyances)
+		t.FailNow()
+	}
+	if len(chainOfTitle.Liens) != 1 || chainOfTitle.ParcelNumber != getValidPropertyDetails().ParcelNumber {
+		fmt.Println("Unexpected chain of title:", chainOfTitle)
+		t.FailNow()
+	}
+
+	anomalyTypes := []string{}
+	for i := 0; i < len(chainOfTitle.Anomalies); i++ {
+		anomalyTypes = append(anomalyTypes, chainOfTitle.Anomalies[i].Type)
+	}
+
+	expectedAnomalyTypes := []string{"gap", "gap", "out-of-order", "missing-sale-price", "missing-name"}
+	if strings.Join(anomalyTypes, ",") != strings.Join(expectedAnomalyTypes, ",") {
+		fmt.Println("Unexpected anomalies:", chainOfTitle.Anomalies)
+		t.FailNow()
+	}
+
+}
+
+func TestSelectStateAsOf(t *testing.T){
+
+	writeTime := func(timestamp string) time.Time {
+		parsed, _ := time.Parse(time.RFC3339, timestamp)
+		return parsed
+	}
+
+	//history is not guaranteed to come back in write order
+	versions := []stateVersion{
+		{Timestamp: writeTime("2018-01-01T00:00:00Z"), Value: []byte("v1")},
+		{Timestamp: writeTime("2018-03-01T00:00:00Z"), Value: []byte("v3")},
+		{Timestamp: writeTime("2018-02-01T00:00:00Z"), Value: []byte("v2")},
+		{Timestamp: writeTime("2018-04-01T00:00:00Z"), IsDelete: true},
+		{Timestamp: writeTime("2018-05-01T00:00:00Z"), Value: []byte("v5")},
+	}
+
+	expectedValues := map[string]string{
+		"2017-12-31T00:00:00Z": "",
+		"2018-01-01T00:00:00Z": "v1",
+		"2018-02-15T00:00:00Z": "v2",
+		"2018-03-01T00:00:00Z": "v3",
+		"2018-04-15T00:00:00Z": "",
+		"2018-06-01T00:00:00Z": "v5",
+	}
+
+	for asOf, expectedValue := range expectedValues {
+		value := selectStateAsOf(versions, writeTime(asOf))
+		if string(value) != expectedValue || (expectedValue == "" && value != nil) {
+			fmt.Println("Unexpected value as of " + asOf + ": " + string(value))
+			t.FailNow()
+		}
+	}
+
+}
+
+func TestMigrateOwnershipShares(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	//thirds recorded as percents before shares existed
+	third := `"percent":0.3333333333333333,"saleDate":"2017-06-28T21:57:16"`
+	addLegacyRecord(stub, property_1, `{"id":"property_1","saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_1",` + third + `},{"id":"ownership_2",` + third + `},{"id":"ownership_3",` + third + `}]}`)
+	for _, ownershipId := range []string{"ownership_1", "ownership_2", "ownership_3"} {
+		addLegacyRecord(stub, ownershipId, `{"properties":[{"id":"property_1",` + third + `}]}`)
+	}
+
+	setCaller(admin)
+	checkInvoke(t, stub, migrateOwnershipShares, getFourArgs(migrateOwnershipShares, "", "10"))
+
+	property := Property{}
+	json.Unmarshal(stub.State[property_1], &property)
+	if confirmValidPercentage(property.Owners) != nil || !hasExactShares(property.Owners) {
+		fmt.Println("Migrated shares do not add up to 1:", string(stub.State[property_1]))
+		t.FailNow()
+	}
+
+	ownership := Ownership{}
+	json.Unmarshal(stub.State["ownership_3"], &ownership)
+	if len(ownership.Properties) != 1 || ownership.Properties[0].Share != property.Owners[2].Share {
+		fmt.Println("Migrated ownership share does not match the property:", string(stub.State["ownership_3"]))
+		t.FailNow()
+	}
+
+}
+
+func TestMigrateOwnershipSharesBadTotal(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	addLegacyRecord(stub, property_2, `{"id":"property_2","saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_1","percent":0.5},{"id":"ownership_2","percent":0.4}]}`)
+
+	setCaller(admin)
+	invalidArgs := getFourArgs(migrateOwnershipShares, "", "10")
+	message := " | " + migrateOwnershipShares + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, migrateOwnershipShares, message, property_2 + ": " + createPropertyTransactionTotalPercentageOfOneError, invalidArgs, property_2)
+
+}
+
+func TestGetPropertyAsOfInvalidTimestamp(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	invalidArgs := getFourArgs(getPropertyAsOf, property_1, "2018-01-15")
+	message := " | " + getPropertyAsOf + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, getPropertyAsOf, message, invalidTimestampError, invalidArgs, property_1)
+
+}
+
+func TestConveyInterest(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller2)
+	conveyanceString := `{"saleDate":"2018-02-01T10:00:00","salePrice":300,"grantees":[{"id":"ownership_4","partyId":"party_4","name":"Dana","share":"1/4"},{"id":"ownership_3","partyId":"party_3","share":"1/20"}]}`
+	checkInvoke(t, stub, conveyInterest, getFiveArgs(conveyInterest, property_1, "ownership_2", conveyanceString))
+
+	property := Property{}
+	json.Unmarshal(stub.State[property_1], &property)
+
+	shares := []string{}
+	for i := 0; i < len(property.Owners); i++ {
+		shares = append(shares, property.Owners[i].Id + "=" + property.Owners[i].Share)
+	}
+	if strings.Join(shares, ",") != "ownership_3=1/2,ownership_2=1/4,ownership_4=1/4" {
+		fmt.Println("Unexpected owners after conveyance:", shares)
+		t.FailNow()
+	}
+
+	checkGetOwnership(t, stub, "ownership_4", `[{"id":"1","saleDate":"2018-02-01T10:00:00","name":"Test Party 4","percent":0.25,"share":"1/4"}]`)
+
+}
+
+func TestConveyInterestMoreThanHeld(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+	conveyanceString := `{"saleDate":"2018-02-01T10:00:00","salePrice":300,"grantees":[{"id":"ownership_4","partyId":"party_4","share":"1/2"}]}`
+	invalidArgs := getFiveArgs(conveyInterest, property_1, "ownership_3", conveyanceString)
+	message := " | " + conveyInterest + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, conveyInterest, message, conveyMoreThanHeldError, invalidArgs, property_1)
+
+}
+
+func TestExecuteEstateTransfer(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+	beneficiariesString := `[{"id":"ownership_5","partyId":"party_5","name":"Eve","share":"2/3"},{"id":"ownership_2","partyId":"party_2","share":"1/3"}]`
+	checkInvoke(t, stub, setBeneficiaries, getFiveArgs(setBeneficiaries, property_1, "ownership_3", beneficiariesString))
+
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, executor, executorRole))
+
+	setCaller(executor)
+	checkInvoke(t, stub, executeEstateTransfer, getFiveArgs(executeEstateTransfer, property_1, "ownership_3", deathCertificateHash))
+
+	property := Property{}
+	json.Unmarshal(stub.State[property_1], &property)
+
+	shares := []string{}
+	for i := 0; i < len(property.Owners); i++ {
+		shares = append(shares, property.Owners[i].Id + "=" + property.Owners[i].Share)
+	}
+	if strings.Join(shares, ",") != "ownership_2=7/10,ownership_5=3/10" || property.SalePrice != 0 {
+		fmt.Println("Unexpected property after estate transfer:", string(stub.State[property_1]))
+		t.FailNow()
+	}
+
+}
+
+func TestExecuteEstateTransferNotExecutor(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+	checkInvoke(t, stub, setBeneficiaries, getFiveArgs(setBeneficiaries, property_1, "ownership_3", `[{"id":"ownership_5","partyId":"party_5","share":"1"}]`))
+
+	invalidArgs := getFiveArgs(executeEstateTransfer, property_1, "ownership_3", deathCertificateHash)
+	message := " | " + executeEstateTransfer + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, executeEstateTransfer, message, missingRoleError, invalidArgs, property_1)
+
+}
+
+func TestExecuteEstateTransferWithoutBeneficiaries(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, executor, executorRole))
+
+	setCaller(executor)
+	invalidArgs := getFiveArgs(executeEstateTransfer, property_1, "ownership_3", deathCertificateHash)
+	message := " | " + executeEstateTransfer + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, executeEstateTransfer, message, noBeneficiariesError, invalidArgs, property_1)
+
+}
+
+func TestPropertyTransactionGift(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	giftString := `{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-2018-0042","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, giftString))
+
+	property := Property{}
+	json.Unmarshal(stub.State[property_1], &property)
+	if property.TransferType != transferGift || property.DeedReference != "DEED-2018-0042" || getPropertyStatus(property) != statusOffMarket {
+		fmt.Println("Unexpected property after gift:", string(stub.State[property_1]))
+		t.FailNow()
+	}
+
+}
+
+func TestPropertyTransactionTransferTypeRules(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+
+	invalidTerms := map[string]string{
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":500,"transferType":"gift","deedReference":"DEED-1","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`: "can not have a sale price",
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`: "requires a deed reference",
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"court-order","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`: "requires a court case number",
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":900,"transferType":"foreclosure","caseNumber":"CV-1","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`: "requires the foreclosed lien id",
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"inheritance","deathCertificateHash":"abc","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`: "hex encoded SHA-256 hash",
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"barter","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`: "Unknown transfer type",
+	}
+
+	for termsString, errorMessage := range invalidTerms {
+
+		invalidArgs := getFourArgs(propertyTransaction, property_1, termsString)
+		message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+		handleExpectedFailures(t, stub, propertyTransaction, message, errorMessage, invalidArgs, termsString)
+
+	}
+
+}
+
+func TestPropertyTransactionForeclosurePaysForeclosedLien(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+
+	foreclosureString := `{"saleDate":"2018-03-01T10:00:00","salePrice":900,"transferType":"foreclosure","caseNumber":"CV-2018-7","foreclosedLienId":"lien_1","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, foreclosureString))
+
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 1 || !liens[0].Satisfied {
+		fmt.Println("Foreclosed lien was not satisfied:", liens)
+		t.FailNow()
+	}
+
+}
+
+func TestPropertyTransactionForeclosureBelowDebt(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_2", 2)))
+
+	foreclosureString := `{"saleDate":"2018-03-01T10:00:00","salePrice":300,"transferType":"foreclosure","caseNumber":"CV-2018-7","foreclosedLienId":"lien_1","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, foreclosureString))
+
+	res := stub.MockInvoke(getSettlement, getFourArgs(getSettlement, property_1, propertyTransaction))
+	settlement := Settlement{}
+	json.Unmarshal(res.Payload, &settlement)
+
+	expectedLienPayoffs := []Payout{{Id: "lien_1", Amount: 300}, {Id: "lien_2", Amount: 0}}
+	if fmt.Sprint(settlement.LienPayoffs) != fmt.Sprint(expectedLienPayoffs) || settlement.Remainder != 0 {
+		fmt.Println("Unexpected settlement:", string(res.Payload))
+		t.FailNow()
+	}
+
+	//the foreclosure extinguishes the foreclosed lien and the junior lien it did not cover
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 2 || !liens[0].Released || liens[0].Satisfied || liens[0].Deficiency != 200 || !liens[1].Released || liens[1].Deficiency != 500 {
+		fmt.Println("Unexpected liens after a foreclosure below the debt:", liens)
+		t.FailNow()
+	}
+
+}
+
+func TestPropertyTransactionForeclosureSeniorLienSurvives(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_1", 1)))
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, getTestLienString("lien_2", 2)))
+
+	foreclosureString := `{"saleDate":"2018-03-01T10:00:00","salePrice":900,"transferType":"foreclosure","caseNumber":"CV-2018-7","foreclosedLienId":"lien_2","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, foreclosureString))
+
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 2 || liens[0].Released || liens[0].Amount != 500 || !liens[1].Satisfied || !liens[1].Released {
+		fmt.Println("Unexpected liens after a junior foreclosure:", liens)
+		t.FailNow()
+	}
+
+}
+
+func TestSplitProperty(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, splitProperty, getFourArgs(splitProperty, property_1, getTestParcelPlansString()))
+
+	child := Property{}
+	json.Unmarshal(stub.State["property_1a"], &child)
+	if !haveSameOwners(child.Owners, getValidOwners()) || child.ParcelNumber != "123-456-001" || strings.Join(child.Parents, ",") != property_1 {
+		fmt.Println("Unexpected child property:", string(stub.State["property_1a"]))
+		t.FailNow()
+	}
+	checkPropertyStatus(t, stub, property_1, statusRetired)
+
+	lineage := checkGetPropertyLineage(t, stub, "property_1b")
+	if strings.Join(lineage.Ancestors, ",") != property_1 {
+		fmt.Println("Unexpected lineage:", lineage)
+		t.FailNow()
+	}
+
+	lineage = checkGetPropertyLineage(t, stub, property_1)
+	if strings.Join(lineage.Descendants, ",") != "property_1a,property_1b" {
+		fmt.Println("Unexpected lineage:", lineage)
+		t.FailNow()
+	}
+
+	invalidArgs := getFourArgs(propertyTransaction, property_1, getBuyerOfferString())
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, retiredPropertyError, invalidArgs, getBuyerOfferString())
+
+}
+
+func TestMergeProperties(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, splitProperty, getFourArgs(splitProperty, property_1, getTestParcelPlansString()))
+
+	mergeString := `{"parents":["property_1a","property_1b"],"details":{"parcelNumber":"123-456-003","legalDescription":"Lot 4, Block 2","address":"12 Main Street","landArea":650.5,"zoning":"R-1"}}`
+	checkInvoke(t, stub, mergeProperties, getFourArgs(mergeProperties, "property_1c", mergeString))
+
+	lineage := checkGetPropertyLineage(t, stub, "property_1c")
+	if strings.Join(lineage.Parents, ",") != "property_1a,property_1b" || strings.Join(lineage.Ancestors, ",") != "property_1a,property_1b,property_1" {
+		fmt.Println("Unexpected lineage:", lineage)
+		t.FailNow()
+	}
+
+	lineage = checkGetPropertyLineage(t, stub, property_1)
+	if strings.Join(lineage.Descendants, ",") != "property_1a,property_1b,property_1c" {
+		fmt.Println("Unexpected lineage:", lineage)
+		t.FailNow()
+	}
+
+}
+
+func TestMergePropertiesDifferentOwners(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	property, propertyString := getTestProperty(property_2, dateString, 1000, []Attribute{{Id: "ownership_4", PartyId: "party_4", Percent: 1, Share: "1"}})
+	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
+
+	mergeString := `{"parents":["property_1","property_2"],"details":{"parcelNumber":"123-456-003","legalDescription":"Lot 4, Block 2","address":"12 Main Street","landArea":900,"zoning":"R-1"}}`
+	invalidArgs := getFourArgs(mergeProperties, "property_3", mergeString)
+	message := " | " + mergeProperties + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, mergeProperties, message, mergeDifferentOwnersError, invalidArgs, mergeString)
+
+	mergeString = `{"parents":["property_1","property_2"],"details":{"parcelNumber":"123-456-003","legalDescription":"Lot 4, Block 2","address":"12 Main Street","landArea":900,"zoning":"R-1"},"owners":[{"id":"ownership_3","partyId":"party_3","share":"1/4"},{"id":"ownership_2","partyId":"party_2","share":"1/4"},{"id":"ownership_4","partyId":"party_4","share":"1/2"}]}`
+	checkInvoke(t, stub, mergeProperties, getFourArgs(mergeProperties, "property_3", mergeString))
+
+	ownershipProperties := []Attribute{}
+	json.Unmarshal(stub.MockInvoke(getOwnership, getThreeArgs(getOwnership, "ownership_4")).Payload, &ownershipProperties)
+	if len(ownershipProperties) != 1 || ownershipProperties[0].Id != "3" || ownershipProperties[0].Share != "1/2" {
+		fmt.Println("Unexpected properties for ownership_4:", ownershipProperties)
+		t.FailNow()
+	}
+
+}
+
+func TestCreateLease(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller2)
+	checkInvoke(t, stub, createLease, getFourArgs(createLease, property_1, getTestLeaseString("1A", tenant, "2018-01-01", "2018-12-31")))
+
+	invalidArgs := getFourArgs(createLease, property_1, getTestLeaseString("", "Org1MSP::tenant2", "2018-06-01", "2019-05-31"))
+	message := " | " + createLease + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, createLease, message, overlappingLeaseError, invalidArgs, property_1)
+
+	checkInvoke(t, stub, "lease_2", getFourArgs(createLease, property_1, getTestLeaseString("1B", tenant, "2018-06-01", "2019-05-31")))
+
+	leases := checkGetActiveLeases(t, stub, getActivePropertyLeases, property_1, "2018-03-01")
+	if len(leases) != 1 || leases[0].Unit != "1A" {
+		fmt.Println("Unexpected active property leases:", leases)
+		t.FailNow()
+	}
+
+	leases = checkGetActiveLeases(t, stub, getActiveTenantLeases, tenant, "2018-07-01")
+	if len(leases) != 2 {
+		fmt.Println("Unexpected active tenant leases:", leases)
+		t.FailNow()
+	}
+
+}
+
+func TestCreateLeaseMinorityOwner(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+
+	invalidArgs := getFourArgs(createLease, property_1, getTestLeaseString("1A", tenant, "2018-01-01", "2018-12-31"))
+	message := " | " + createLease + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, createLease, message, minorityOwnerError, invalidArgs, property_1)
+
+}
+
+func TestRecordRentCarriesRemainders(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	property, propertyString := getTestProperty(property_2, dateString, 1000, []Attribute{{Id: "ownership_3", PartyId: "party_3", Percent: 1.0 / 3, Share: "1/3"}, {Id: "ownership_2", PartyId: "party_2", Percent: 2.0 / 3, Share: "2/3"}})
+	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
+
+	setCaller(seller3)
+	checkInvoke(t, stub, "rent_1", getFiveArgs(recordRent, property_2, "ownership_3", `{"amount":100,"paymentDate":"2018-01-01"}`))
+	checkInvoke(t, stub, "rent_2", getFiveArgs(recordRent, property_2, "ownership_3", `{"amount":100,"paymentDate":"2018-02-01"}`))
+	checkInvoke(t, stub, "rent_3", getFiveArgs(recordRent, property_2, "ownership_3", `{"amount":100,"paymentDate":"2018-03-01"}`))
+
+	income := checkGetOwnerIncome(t, stub, "ownership_3", "2018-01-01", "2018-03-31")
+	cents := []string{}
+	for i := 0; i < len(income.Entries); i++ {
+		cents = append(cents, strconv.FormatInt(income.Entries[i].Cents, 10))
+	}
+	if strings.Join(cents, ",") != "3333,3333,3334" || income.Total != 100 {
+		fmt.Println("Unexpected income for ownership_3:", income)
+		t.FailNow()
+	}
+
+	income = checkGetOwnerIncome(t, stub, "ownership_2", "2018-02-01", "2018-02-28")
+	if len(income.Entries) != 1 || income.Entries[0].Cents != 6667 || income.Entries[0].CarriedRemainder != "1/3" {
+		fmt.Println("Unexpected income for ownership_2:", income)
+		t.FailNow()
+	}
+
+}
+
+func TestRecordRentNotOwner(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(buyer)
+
+	invalidArgs := getFiveArgs(recordRent, property_1, "ownership_3", `{"amount":100,"paymentDate":"2018-01-01"}`)
+	message := " | " + recordRent + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, recordRent, message, acceptOfferNotControllerError, invalidArgs, property_1)
+
+}
+
+func TestDelinquentTaxesBlockPropertyTransaction(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, assessor, assessorRole))
+	checkInvoke(t, stub, setTaxDelinquencyDays, getThreeArgs(setTaxDelinquencyDays, "30"))
+
+	setCaller(assessor)
+	checkInvoke(t, stub, recordAssessment, getFourArgs(recordAssessment, property_1, `{"taxYear":2016,"assessedValue":90000,"taxAmount":1200,"dueDate":"2017-01-31"}`))
+	checkInvoke(t, stub, recordTaxPayment, getFiveArgs(recordTaxPayment, property_1, "2016", `{"amount":200,"paymentDate":"2017-01-15"}`))
+
+	delinquency := TaxDelinquency{}
+	json.Unmarshal(stub.MockInvoke(getTaxDelinquency, getThreeArgs(getTaxDelinquency, property_1)).Payload, &delinquency)
+	if !delinquency.Delinquent || delinquency.AmountDue != 1000 {
+		fmt.Println("Unexpected tax delinquency:", delinquency)
+		t.FailNow()
+	}
+
+	//the bill was past due when it was assessed, so the lien is already recorded
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 1 || liens[0].LienId != "tax-2016" || liens[0].Amount != 1000 {
+		fmt.Println("Tax lien was not recorded with the assessment:", liens)
+		t.FailNow()
+	}
+
+	lienIds := []string{}
+	json.Unmarshal(stub.MockInvoke(applyTaxLiens, getThreeArgs(applyTaxLiens, property_1)).Payload, &lienIds)
+	if len(lienIds) != 0 {
+		fmt.Println("applyTaxLiens recorded a second tax lien:", lienIds)
+		t.FailNow()
+	}
+
+	setCaller(seller3)
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+
+	setCaller(registrar)
+	invalidArgs := getFourArgs(propertyTransaction, property_1, getBuyerOfferString())
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, unreleasedLienError, invalidArgs, getBuyerOfferString())
+
+	saleString := `{"saleDate":"2018-01-15T10:00:00","salePrice":2000,"owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}],"lienPayoffs":["tax-2016"]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, saleString))
+
+	json.Unmarshal(stub.MockInvoke(getTaxDelinquency, getThreeArgs(getTaxDelinquency, property_1)).Payload, &delinquency)
+	if delinquency.Delinquent {
+		fmt.Println("Tax lien payoff did not pay the tax bill:", delinquency)
+		t.FailNow()
+	}
+
+}
+
+func TestRecordLienClearsStatus(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	lienString := `{"lienId":"lien_1","holder":"First Bank","amount":500,"priority":1,"recordingDate":"2017-07-01T00:00:00Z","released":true,"satisfied":true,"deficiency":200}`
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, lienString))
+
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 1 || liens[0].Released || liens[0].Satisfied || liens[0].Deficiency != 0 {
+		fmt.Println("A new lien was recorded with a status:", liens)
+		t.FailNow()
+	}
+
+}
+
+func TestRecordLienReservedTaxId(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	invalidArgs := getFourArgs(recordLien, property_1, getTestLienString("tax-2016", 1))
+	message := " | " + recordLien + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, recordLien, message, reservedLienIdError, invalidArgs, property_1)
+
+}
+
+func TestRecordedLienDoesNotPayTaxBill(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, assessor, assessorRole))
+	checkInvoke(t, stub, setTaxDelinquencyDays, getThreeArgs(setTaxDelinquencyDays, "30"))
+
+	setCaller(assessor)
+	checkInvoke(t, stub, recordAssessment, getFourArgs(recordAssessment, property_1, `{"taxYear":2016,"assessedValue":90000,"taxAmount":1200,"dueDate":"2017-01-31"}`))
+	checkInvoke(t, stub, recordTaxPayment, getFiveArgs(recordTaxPayment, property_1, "2016", `{"amount":200,"paymentDate":"2017-01-15"}`))
+
+	//a registrar can not make a lien pay a tax bill off
+	setCaller(registrar)
+	checkInvoke(t, stub, recordLien, getFourArgs(recordLien, property_1, `{"lienId":"lien_1","holder":"First Bank","amount":500,"priority":1,"recordingDate":"2017-07-01T00:00:00Z","taxYear":2016}`))
+
+	setCaller(seller3)
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+
+	setCaller(registrar)
+	saleString := `{"saleDate":"2018-01-15T10:00:00","salePrice":2000,"owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}],"lienPayoffs":["lien_1","tax-2016"]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, saleString))
+
+	bill, err := getTaxBillFromLedger(stub, property_1, 2016)
+	if err != nil || bill.AmountPaid != 1200 || len(bill.Payments) != 2 || bill.Payments[1].Amount != 1000 {
+		fmt.Println("Unexpected tax bill after the lien payoffs:", bill, err)
+		t.FailNow()
+	}
+
+}
+
+func TestTaxLienCollision(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, assessor, assessorRole))
+	checkInvoke(t, stub, setTaxDelinquencyDays, getThreeArgs(setTaxDelinquencyDays, "30"))
+
+	//a lien recorded under the tax lien id before the id was reserved
+	lienKey, _ := stub.CreateCompositeKey("lien", []string{property_1, "tax-2016"})
+	lienBytes, _ := json.Marshal(Lien{LienId: "tax-2016", PropertyId: property_1, Holder: "First Bank", Amount: 500, Priority: 1})
+	stub.MockTransactionStart("legacyLien")
+	stub.PutState(lienKey, lienBytes)
+	stub.MockTransactionEnd("legacyLien")
+
+	setCaller(assessor)
+	invalidArgs := getFourArgs(recordAssessment, property_1, `{"taxYear":2016,"assessedValue":90000,"taxAmount":1200,"dueDate":"2017-01-31"}`)
+	message := " | " + recordAssessment + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, recordAssessment, message, taxLienCollisionError, invalidArgs, property_1)
+
+	liens := checkGetEncumbrances(t, stub, property_1)
+	if len(liens) != 1 || liens[0].Holder != "First Bank" {
+		fmt.Println("Recorded lien was overwritten:", liens)
+		t.FailNow()
+	}
+
+}
+
+func TestGetDelinquentProperties(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, assessor, assessorRole))
+
+	setCaller(registrar)
+	property, propertyString := getTestProperty(property_2, dateString, 1000, getValidOwners())
+	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
+
+	setCaller(assessor)
+	checkInvoke(t, stub, recordAssessment, getFourArgs(recordAssessment, property_1, `{"taxYear":2015,"assessedValue":90000,"taxAmount":1200,"dueDate":"2016-01-31"}`))
+	checkInvoke(t, stub, recordAssessment, getFourArgs(recordAssessment, property_2, `{"taxYear":2015,"assessedValue":90000,"taxAmount":1200,"dueDate":"2016-01-31"}`))
+	checkInvoke(t, stub, recordTaxPayment, getFiveArgs(recordTaxPayment, property_2, "2015", `{"amount":1200,"paymentDate":"2016-01-15"}`))
+
+	delinquencies := []TaxDelinquency{}
+	json.Unmarshal(stub.MockInvoke(getDelinquentProperties, [][]byte{[]byte(getDelinquentProperties), []byte(getDelinquentProperties)}).Payload, &delinquencies)
+	if len(delinquencies) != 1 || delinquencies[0].PropertyId != property_1 {
+		fmt.Println("Unexpected delinquent properties:", delinquencies)
+		t.FailNow()
+	}
+
+}
+
+func TestAttachAndVerifyDocument(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+
+	setCaller(registrar)
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, getBuyerOfferString()))
+
+	documentString := `{"type":"deed","filename":"deed.pdf","hash":"` + deedHash + `","size":48213}`
+	checkInvoke(t, stub, attachDocument, getFiveArgs(attachDocument, property_1, propertyTransaction, documentString))
+
+	res := stub.MockInvoke(verifyDocument, getThreeArgs(verifyDocument, strings.ToUpper(deedHash)))
+	verification := DocumentVerification{}
+	json.Unmarshal(res.Payload, &verification)
+	if !verification.Verified || len(verification.Documents) != 1 || verification.Documents[0].PropertyId != property_1 || verification.Documents[0].TxId != propertyTransaction || verification.Documents[0].Uploader != registrar {
+		fmt.Println("Unexpected document verification:", string(res.Payload))
+		t.FailNow()
+	}
+
+	res = stub.MockInvoke(verifyDocument, getThreeArgs(verifyDocument, deathCertificateHash))
+	json.Unmarshal(res.Payload, &verification)
+	if verification.Verified {
+		fmt.Println("Unattached document was verified:", string(res.Payload))
+		t.FailNow()
+	}
+
+}
+
+func TestAttachDocumentNotOwner(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(buyer)
+
+	documentString := `{"type":"deed","filename":"deed.pdf","hash":"` + deedHash + `","size":48213}`
+	invalidArgs := getFiveArgs(attachDocument, property_1, propertyTransaction, documentString)
+	message := " | " + attachDocument + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, attachDocument, message, documentUploaderError, invalidArgs, property_1)
+
+}
+
+func TestAttachDocumentConveyanceParties(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(seller3)
+	checkInvoke(t, stub, markUnderContract, getFourArgs(markUnderContract, property_1, "ownership_3"))
+
+	setCaller(registrar)
+	checkInvoke(t, stub, "sale_1", getFourArgs(propertyTransaction, property_1, getBuyerOfferString()))
+	checkInvoke(t, stub, assignOwnershipController, getFourArgs(assignOwnershipController, "ownership_4", buyer))
+
+	//the grantors and grantees of a conveyance can attach its documents
+	setCaller(seller3)
+	deedString := `{"type":"deed","filename":"deed.pdf","hash":"` + deedHash + `","size":48213}`
+	checkInvoke(t, stub, attachDocument, getFiveArgs(attachDocument, property_1, "sale_1", deedString))
+
+	setCaller(buyer)
+	receiptString := `{"type":"receipt","filename":"receipt.pdf","hash":"` + deathCertificateHash + `","size":1024}`
+	checkInvoke(t, stub, attachDocument, getFiveArgs(attachDocument, property_1, "sale_1", receiptString))
+
+	//but not those of a conveyance they were not part of
+	invalidArgs := getFiveArgs(attachDocument, property_1, propertyTransaction, deedString)
+	message := " | " + attachDocument + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, attachDocument, message, documentUploaderError, invalidArgs, property_1)
+
+}
+
+func TestPropertyTransactionUnverifiedParty(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	partyString := `{"type":"company","legalName":"Riverside Holdings LLC","identifiers":[{"type":"companyNumber","value":"C-1042"}]}`
+	checkInvoke(t, stub, registerParty, getFourArgs(registerParty, "party_6", partyString))
+
+	propertyString := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_6","partyId":"party_6","share":"1"}]}`
+	invalidArgs := getFourArgs(propertyTransaction, property_1, propertyString)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, propertyTransaction, message, unverifiedPartyError, invalidArgs, propertyString)
+
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, compliance, complianceRole))
+	setCaller(compliance)
+	checkInvoke(t, stub, setKycStatus, getFourArgs(setKycStatus, "party_6", kycVerified))
+
+	setCaller(registrar)
+	checkInvoke(t, stub, propertyTransaction, invalidArgs)
+
+}
+
+func TestPropertyTransactionUnknownParty(t *testing.T){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	propertyString := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_6","partyId":"party_6","share":"1"}]}`
+	invalidArgs := getFourArgs(propertyTransaction, property_1, propertyString)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
 
-	checkGetOwnership(t, stub, ownershipInputList[0].Id, ownershipProperty)
+	handleExpectedFailures(t, stub, propertyTransaction, message, unknownPartyError, invalidArgs, propertyString)
 
 }
 
-func TestPropertyTransaction(t *testing.T){
+func TestPropertyTransactionMissingPartyId(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithRole(registrarRole, registrar)
 
-	property, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+	namedOwnerString := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_6","name":"Fay","share":"1"}]}`
+	invalidArgs := getFourArgs(propertyTransaction, property_1, namedOwnerString)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
 
-	checkPropertyTransaction(t,stub, property.PropertyId, propertyString)
-	checkPropertyState(t, stub, property, propertyString)
+	handleExpectedFailures(t, stub, propertyTransaction, message, missingPartyIdError, invalidArgs, namedOwnerString)
 
 }
 
-func TestPropertyTransactionExtraArgs(t *testing.T) {
+func TestPropertyTransactionDropsPartyNames(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithRole(registrarRole, registrar)
 
-	invalidArgument := "invalidArgument"
-	_, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+	propertyString := `{"id":"property_1","saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_1","partyId":"party_1","name":"Fay","share":"1"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, propertyString))
 
-	invalidArgs := getFourArgs(propertyTransaction, property_1, propertyString)
-	invalidArgs = append(invalidArgs, []byte(invalidArgument))
+	//the stored owner only refers to the party, the name comes from the registry
+	propertyBytes, _ := stub.GetState(property_1)
+	property := Property{}
+	json.Unmarshal(propertyBytes, &property)
+	if len(property.Owners) != 1 || property.Owners[0].Name != "" {
+		fmt.Println("Owner bound to a party was stored with a name:", property.Owners)
+		t.FailNow()
+	}
 
-	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + ", " + string(invalidArgs[2]) + ", " + string(invalidArgs[3]) + ", " + string(invalidArgs[4]) + "}, did not fail. "
+	ownershipBytes, _ := stub.GetState("ownership_1")
+	ownership := Ownership{}
+	json.Unmarshal(ownershipBytes, &ownership)
+	if len(ownership.Properties) != 1 || ownership.Properties[0].Name != "" {
+		fmt.Println("Ownership bound to a party was stored with a name:", ownership.Properties)
+		t.FailNow()
+	}
 
-	handleExpectedFailures(t, stub, propertyTransaction, message, incorrectArgCountError, invalidArgs, propertyString)
+	checkGetOwnership(t, stub, "ownership_1", `[{"id":"1","saleDate":"2017-06-28T21:57:16","name":"Test Party 1","percent":1,"share":"1"}]`)
 
 }
 
-func TestPropertyTransactionStringAsSalePrice(t *testing.T) {
+func TestPropertyTransactionOwnershipBoundToParty(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithOwnedProperty(t)
 
-	validJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":"1000","owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownerhip_2","percentage":0.55}]}`
+	setCaller(registrar)
+	propertyString := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_3","partyId":"party_4","share":"1"}]}`
+	invalidArgs := getFourArgs(propertyTransaction, property_2, propertyString)
+	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
 
-	invalidArgs := getFourArgs(propertyTransaction, property_1, validJson)
+	handleExpectedFailures(t, stub, propertyTransaction, message, ownershipBoundError, invalidArgs, propertyString)
 
-	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+}
 
-	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionUnmarshalWrongTypeError, invalidArgs, validJson )
+func TestUpdatePartyRename(t *testing.T){
 
-}
+	stub := getStubWithRole(registrarRole, registrar)
 
-func TestPropertyTransactionMissingSaleDate(t *testing.T) {
+	checkInvoke(t, stub, updateParty, getFourArgs(updateParty, "party_3", `{"type":"person","legalName":"Ann Smith-Jones","identifiers":[]}`))
 
-	stub := getStub()
+	party := Party{}
+	json.Unmarshal(stub.MockInvoke(getParty, getThreeArgs(getParty, "party_3")).Payload, &party)
+	if party.LegalName != "Ann Smith-Jones" || party.KycStatus != kycVerified {
+		fmt.Println("Unexpected party after update:", party)
+		t.FailNow()
+	}
 
-	missingSaleDateJson := `{"salePrice":1000,"owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownership_2","percentage":0.55}]}`
+}
 
-	invalidArgs := getFourArgs(propertyTransaction, property_1, missingSaleDateJson)
-	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+func TestPropertyTransactionTenancyRules(t *testing.T){
 
-	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionMissingSaleDateError, invalidArgs, missingSaleDateJson)
+	stub := getStubWithOwnedProperty(t)
 
-}
+	setCaller(registrar)
 
-func TestPropertyTransactionNegativeSalePrice(t *testing.T) {
+	invalidTerms := map[string]string{
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-1","tenancy":"sole","owners":[{"id":"ownership_4","partyId":"party_4","share":"1/2"},{"id":"ownership_5","partyId":"party_5","share":"1/2"}]}`: "Sole ownership must have exactly 1 owner",
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-1","tenancy":"joint-tenancy","owners":[{"id":"ownership_4","partyId":"party_4","share":"1/4"},{"id":"ownership_5","partyId":"party_5","share":"3/4"}]}`: "must hold equal shares",
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-1","tenancy":"community-property","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`: "Community property must have exactly 2 owners",
+		`{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-1","tenancy":"tenancy-by-the-entirety","owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`: "Invalid tenancy",
+	}
 
-	stub := getStub()
+	for termsString, errorMessage := range invalidTerms {
 
-	negativeSalePriceJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":-1,"owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownerhip_2","percentage":0.55}]}`
+		invalidArgs := getFourArgs(propertyTransaction, property_1, termsString)
+		message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
 
-	invalidArgs := getFourArgs(propertyTransaction, property_1, negativeSalePriceJson)
-	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+		handleExpectedFailures(t, stub, propertyTransaction, message, errorMessage, invalidArgs, termsString)
 
-	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionGreaterThanZeroSalePriceError, invalidArgs, negativeSalePriceJson)
+	}
 
 }
 
-func TestPropertyTransactionNoOwners(t *testing.T) {
+func TestExecuteEstateTransferJointTenancy(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithOwnedProperty(t)
 
-	noOwnersJson := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[]}`
-	
-	invalidArgs := getFourArgs(propertyTransaction, property_1, noOwnersJson)
-	message:= " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+	setCaller(registrar)
+	giftString := `{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-2018-0042","tenancy":"joint-tenancy","owners":[{"id":"ownership_2","partyId":"party_2","share":"1/3"},{"id":"ownership_3","partyId":"party_3","share":"1/3"},{"id":"ownership_4","partyId":"party_4","share":"1/3"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, giftString))
 
-	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionNoOwnersError, invalidArgs, noOwnersJson)
+	setCaller(seller3)
+	invalidArgs := getFiveArgs(setBeneficiaries, property_1, "ownership_3", `[{"id":"ownership_5","partyId":"party_5","share":"1"}]`)
+	message := " | " + setBeneficiaries + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+	handleExpectedFailures(t, stub, setBeneficiaries, message, survivorshipError, invalidArgs, property_1)
+
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, executor, executorRole))
+
+	setCaller(executor)
+	checkInvoke(t, stub, executeEstateTransfer, getFiveArgs(executeEstateTransfer, property_1, "ownership_3", deathCertificateHash))
+	checkTenancy(t, stub, property_1, tenancyJoint, "ownership_2=1/2,ownership_4=1/2")
+
+	checkInvoke(t, stub, executeEstateTransfer, getFiveArgs(executeEstateTransfer, property_1, "ownership_2", deathCertificateHash))
+	checkTenancy(t, stub, property_1, tenancySole, "ownership_4=1")
 
 }
 
-func TestPropertyTransactionTooLowTotalOwnerPercentage(t *testing.T) {
+func TestExecuteEstateTransferCommunityProperty(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithOwnedProperty(t)
 
-	tooLowOwnerPercentage := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownerhip_2","percentage":0.50}]}`
+	setCaller(registrar)
+	giftString := `{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-2018-0042","tenancy":"community-property","owners":[{"id":"ownership_2","partyId":"party_2","share":"1/2"},{"id":"ownership_3","partyId":"party_3","share":"1/2"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, giftString))
 
-	invalidArgs := getFourArgs(propertyTransaction, property_1, tooLowOwnerPercentage)
-	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+	//a spouse's half of community property passes by will rather than to the other spouse
+	setCaller(seller3)
+	checkInvoke(t, stub, setBeneficiaries, getFiveArgs(setBeneficiaries, property_1, "ownership_3", `[{"id":"ownership_5","partyId":"party_5","share":"1"}]`))
 
-	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionTotalPercentageOfOneError, invalidArgs, tooLowOwnerPercentage)
+	setCaller(admin)
+	checkInvoke(t, stub, assignRole, getFourArgs(assignRole, executor, executorRole))
+
+	setCaller(executor)
+	checkInvoke(t, stub, executeEstateTransfer, getFiveArgs(executeEstateTransfer, property_1, "ownership_3", deathCertificateHash))
+	checkTenancy(t, stub, property_1, tenancyInCommon, "ownership_2=1/2,ownership_5=1/2")
 
 }
 
-func TestPropertyTransactionTooHighTotalOwnerPercentage(t *testing.T) {
+func TestConveyInterestJointTenancy(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithOwnedProperty(t)
 
-	tooHighOwnerPercentage := `{"saleDate":"2017-06-28T21:57:16","salePrice":1000,"owners":[{"id":"ownership_3","percentage":0.45},{"id":"ownerhip_2","percentage":0.70}]}`
+	setCaller(registrar)
+	giftString := `{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-2018-0042","tenancy":"joint-tenancy","owners":[{"id":"ownership_2","partyId":"party_2","share":"1/2"},{"id":"ownership_3","partyId":"party_3","share":"1/2"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, giftString))
 
-	invalidArgs := getFourArgs(propertyTransaction, property_1, tooHighOwnerPercentage)
-	message := " | " + propertyTransaction + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "}, did not fail. "
+	setCaller(seller2)
+	conveyanceString := `{"saleDate":"2018-04-01T10:00:00","salePrice":300,"grantees":[{"id":"ownership_4","partyId":"party_4","share":"1/4"}]}`
+	invalidArgs := getFiveArgs(conveyInterest, property_1, "ownership_2", conveyanceString)
+	message := " | " + conveyInterest + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+	handleExpectedFailures(t, stub, conveyInterest, message, jointTenantConveyanceError, invalidArgs, property_1)
 
-	handleExpectedFailures(t, stub, propertyTransaction, message, createPropertyTransactionTotalPercentageOfOneError, invalidArgs, tooHighOwnerPercentage)
+	//conveying the whole interest severs the joint tenancy
+	conveyanceString = `{"saleDate":"2018-04-01T10:00:00","salePrice":600,"grantees":[{"id":"ownership_4","partyId":"party_4","share":"1/2"}]}`
+	checkInvoke(t, stub, conveyInterest, getFiveArgs(conveyInterest, property_1, "ownership_2", conveyanceString))
+	checkTenancy(t, stub, property_1, tenancyInCommon, "ownership_3=1/2,ownership_4=1/2")
 
 }
 
-func TestGetProperty(t *testing.T){
+func TestConveyInterestCommunityProperty(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithOwnedProperty(t)
 
-	property, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+	setCaller(registrar)
+	giftString := `{"saleDate":"2018-03-01T10:00:00","salePrice":0,"transferType":"gift","deedReference":"DEED-2018-0042","tenancy":"community-property","owners":[{"id":"ownership_2","partyId":"party_2","share":"1/2"},{"id":"ownership_3","partyId":"party_3","share":"1/2"}]}`
+	checkInvoke(t, stub, propertyTransaction, getFourArgs(propertyTransaction, property_1, giftString))
 
-	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
-	checkGetProperty(t, stub, property, propertyString)
+	setCaller(seller2)
+	conveyanceString := `{"saleDate":"2018-04-01T10:00:00","salePrice":600,"grantees":[{"id":"ownership_4","partyId":"party_4","share":"1/2"}]}`
+	invalidArgs := getFiveArgs(conveyInterest, property_1, "ownership_2", conveyanceString)
+	message := " | " + conveyInterest + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "," + string(invalidArgs[4]) + "}, did not fail. "
+	handleExpectedFailures(t, stub, conveyInterest, message, communityPropertyConveyanceError, invalidArgs, property_1)
 
 }
 
-func TestGetPropertyExtraArgs(t *testing.T){
+func TestGetBeneficialOwners(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithOwnedProperty(t)
 
-	property, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+	setCaller(registrar)
+	checkInvoke(t, stub, updateParty, getFourArgs(updateParty, "party_3", `{"type":"company","legalName":"Smith Holdings LLC","identifiers":[]}`))
+	checkInvoke(t, stub, registerParty, getFourArgs(registerParty, "party_6", `{"type":"trust","legalName":"Smith Family Trust","identifiers":[]}`))
+	checkInvoke(t, stub, setShareholders, getFourArgs(setShareholders, "party_3", `[{"partyId":"party_4","share":"1/2"},{"partyId":"party_6","percent":0.5}]`))
+	checkInvoke(t, stub, setShareholders, getFourArgs(setShareholders, "party_6", `[{"partyId":"party_4","share":"1/4"},{"partyId":"party_5","share":"3/4"}]`))
 
-	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
+	ownership := checkGetBeneficialOwners(t, stub, property_1)
 
-	invalidArgs := getFourArgs(getProperty, property.PropertyId, propertyString)
+	shares := []string{}
+	for i := 0; i < len(ownership.Owners); i++ {
+		shares = append(shares, ownership.Owners[i].PartyId + "=" + ownership.Owners[i].Share)
+	}
+	if strings.Join(shares, ",") != "party_2=11/20,party_4=9/32,party_5=27/160" {
+		fmt.Println("Unexpected beneficial owners:", shares)
+		t.FailNow()
+	}
 
-	message:= " | " + getProperty + " with args: {" + string(invalidArgs[1]) + "," + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
+	paths := ownership.Owners[1].Paths
+	if len(paths) != 2 || strings.Join(paths[1], ",") != "party_3,party_6,party_4" {
+		fmt.Println("Unexpected beneficial ownership paths:", paths)
+		t.FailNow()
+	}
 
-	handleExpectedFailures(t, stub, getProperty, message, incorrectArgCountError, invalidArgs, propertyString)
+}
+
+func TestGetBeneficialOwnersCycle(t *testing.T){
+
+	stub := getStubWithOwnedProperty(t)
+
+	setCaller(registrar)
+	checkInvoke(t, stub, updateParty, getFourArgs(updateParty, "party_3", `{"type":"company","legalName":"Smith Holdings LLC","identifiers":[]}`))
+	checkInvoke(t, stub, registerParty, getFourArgs(registerParty, "party_6", `{"type":"company","legalName":"Smith Nominees Ltd","identifiers":[]}`))
+	checkInvoke(t, stub, setShareholders, getFourArgs(setShareholders, "party_3", `[{"partyId":"party_6","share":"1"}]`))
+	checkInvoke(t, stub, setShareholders, getFourArgs(setShareholders, "party_6", `[{"partyId":"party_3","share":"1"}]`))
+
+	invalidArgs := getThreeArgs(getBeneficialOwners, property_1)
+	message := " | " + getBeneficialOwners + " with args: {" + string(invalidArgs[2]) + "}, did not fail. "
+
+	handleExpectedFailures(t, stub, getBeneficialOwners, message, ownershipCycleError, invalidArgs, property_1)
 
 }
 
-func TestGetPropertyMissingProperty(t *testing.T){
+func TestSetShareholdersPerson(t *testing.T){
 
-	stub := getStub()
+	stub := getStubWithRole(registrarRole, registrar)
 
-	invalidArgs := getThreeArgs(getProperty, property_1)
-	message := " | " + getProperty + " with args: {" + property_1 + "}, did not fail. "
+	invalidArgs := getFourArgs(setShareholders, "party_2", `[{"partyId":"party_4","share":"1"}]`)
+	message := " | " + setShareholders + " with args: {" + string(invalidArgs[2]) + "," + string(invalidArgs[3]) + "}, did not fail. "
 
-	handleExpectedFailures(t, stub, getProperty, message, getPropertyNilError, invalidArgs, emptyPropertyJson)
+	handleExpectedFailures(t, stub, setShareholders, message, personShareholdersError, invalidArgs, "party_2")
 
 }
 
@@ -357,6 +1986,18 @@ func getFourArgs(function string, id string, jsonStruct string) ([][]byte) {
 	return args
 }
 
+func getFiveArgs(function string, id string, secondId string, jsonStruct string) ([][]byte) {
+
+	method := []byte(function)
+	key := []byte(id)
+	secondKey := []byte(secondId)
+	value := []byte(jsonStruct)
+
+	args := [][]byte{method, method, key, secondKey, value}
+
+	return args
+}
+
 func getThreeArgs(function string, id string) ([][]byte) {
 
 	method := []byte(function)
@@ -372,11 +2013,15 @@ func getValidOwners() []Attribute {
 	owner1 := Attribute{}
 	owner2 := Attribute{}
 	owner1.Id = "ownership_3"
+	owner1.PartyId = "party_3"
 	owner1.Percent = 0.45
+	owner1.Share = "9/20"
 	owner1.SaleDate = dateString
 
 	owner2.Id = "ownership_2"
+	owner2.PartyId = "party_2"
 	owner2.Percent = 0.55
+	owner2.Share = "11/20"
 	owner2.SaleDate = dateString
 
 	ownershipInputList := []Attribute{owner1, owner2}
@@ -392,10 +2037,12 @@ func getValidOwnersProperty1() []Attribute{
 
 	property1.Id = "1"
 	property1.Percent = 0.45
+	property1.Share = "9/20"
 	property1.SaleDate = dateString
 
 	property2.Id = "1"
 	property2.Percent = 0.55
+	property2.Share = "11/20"
 	property2.SaleDate = dateString
 
 	ownershipInputList := []Attribute{property1, property2}
@@ -441,11 +2088,275 @@ func getAttributesAsString(attributes []Attribute) string{
 
 }
 
+func checkPropertyStatus(t *testing.T, stub *shim.MockStub, propertyId string, status string){
+
+	property := Property{}
+	json.Unmarshal(stub.State[propertyId], &property)
+	if getPropertyStatus(property) != status {
+		fmt.Println("Property", propertyId, "status was", getPropertyStatus(property), "not", status)
+		t.FailNow()
+	}
+
+}
+
+func checkInvoke(t *testing.T, stub *shim.MockStub, function string, args [][]byte){
+
+	res := stub.MockInvoke(function, args)
+	if res.Status != shim.OK {
+		fmt.Println(" | " + function + " failed. [res.Message=" + res.Message + "]")
+		t.FailNow()
+	}
+
+}
+
+//getStubWithOwnedProperty records property_1 for ownership_3 and ownership_2, whose
+//controllers are seller3 and seller2
+func getStubWithOwnedProperty(t *testing.T) (*shim.MockStub){
+
+	stub := getStubWithRole(registrarRole, registrar)
+
+	property, propertyString := getTestProperty(property_1, dateString, 1000, getValidOwners())
+	checkPropertyTransaction(t, stub, property.PropertyId, propertyString)
+
+	checkInvoke(t, stub, assignOwnershipController, getFourArgs(assignOwnershipController, "ownership_3", seller3))
+	checkInvoke(t, stub, assignOwnershipController, getFourArgs(assignOwnershipController, "ownership_2", seller2))
+
+	return stub
+
+}
+
+func checkGetEncumbrances(t *testing.T, stub *shim.MockStub, propertyId string) []Lien{
+
+	res := stub.MockInvoke(getEncumbrances, getThreeArgs(getEncumbrances, propertyId))
+	if res.Status != shim.OK {
+		fmt.Println(" | " + getEncumbrances + " failed. [res.Message=" + res.Message + "]")
+		t.FailNow()
+	}
+
+	liens := []Lien{}
+	json.Unmarshal(res.Payload, &liens)
+
+	return liens
+
+}
+
+func getTestLienString(lienId string, priority int) string {
+
+	return `{"lienId":"` + lienId + `","holder":"First Bank","amount":500,"priority":` + strconv.Itoa(priority) + `,"recordingDate":"2017-07-01T00:00:00Z"}`
+
+}
+
+func checkGetPropertyLineage(t *testing.T, stub *shim.MockStub, propertyId string) PropertyLineage{
+
+	res := stub.MockInvoke(getPropertyLineage, getThreeArgs(getPropertyLineage, propertyId))
+	if res.Status != shim.OK {
+		fmt.Println(" | " + getPropertyLineage + " failed. [res.Message=" + res.Message + "]")
+		t.FailNow()
+	}
+
+	lineage := PropertyLineage{}
+	json.Unmarshal(res.Payload, &lineage)
+
+	return lineage
+
+}
+
+func getTestParcelPlansString() string {
+
+	return `[{"id":"property_1a","parcelNumber":"123-456-001","legalDescription":"Lot 4A, Block 2","address":"12A Main Street","landArea":300,"zoning":"R-1"},` +
+		`{"id":"property_1b","parcelNumber":"123-456-002","legalDescription":"Lot 4B, Block 2","address":"12B Main Street","landArea":350.5,"zoning":"R-1"}]`
+
+}
+
+func checkGetActiveLeases(t *testing.T, stub *shim.MockStub, function string, id string, date string) []Lease{
+
+	res := stub.MockInvoke(function, getFourArgs(function, id, date))
+	if res.Status != shim.OK {
+		fmt.Println(" | " + function + " failed. [res.Message=" + res.Message + "]")
+		t.FailNow()
+	}
+
+	leases := []Lease{}
+	json.Unmarshal(res.Payload, &leases)
+
+	return leases
+
+}
+
+func getTestLeaseString(unit string, tenant string, startDate string, endDate string) string {
+
+	return `{"unit":"` + unit + `","tenant":"` + tenant + `","startDate":"` + startDate + `","endDate":"` + endDate + `","rentAmount":1200,"paymentFrequency":"monthly","deposit":2400}`
+
+}
+
+func checkGetOwnerIncome(t *testing.T, stub *shim.MockStub, ownershipId string, fromDate string, toDate string) OwnerIncome{
+
+	res := stub.MockInvoke(getOwnerIncome, getFiveArgs(getOwnerIncome, ownershipId, fromDate, toDate))
+	if res.Status != shim.OK {
+		fmt.Println(" | " + getOwnerIncome + " failed. [res.Message=" + res.Message + "]")
+		t.FailNow()
+	}
+
+	income := OwnerIncome{}
+	json.Unmarshal(res.Payload, &income)
+
+	return income
+
+}
+
+func checkTenancy(t *testing.T, stub *shim.MockStub, propertyId string, tenancy string, expectedShares string) {
+
+	property := Property{}
+	json.Unmarshal(stub.State[propertyId], &property)
+
+	shares := []string{}
+	for i := 0; i < len(property.Owners); i++ {
+		shares = append(shares, property.Owners[i].Id + "=" + property.Owners[i].Share)
+	}
+	if property.Tenancy != tenancy || strings.Join(shares, ",") != expectedShares {
+		fmt.Println("Unexpected tenancy or owners:", string(stub.State[propertyId]))
+		t.FailNow()
+	}
+
+}
+
+func checkGetBeneficialOwners(t *testing.T, stub *shim.MockStub, propertyId string) BeneficialOwnership {
+
+	res := stub.MockInvoke(getBeneficialOwners, getThreeArgs(getBeneficialOwners, propertyId))
+	if res.Status != shim.OK {
+		fmt.Println(" | " + getBeneficialOwners + " failed. [res.Message=" + res.Message + "]")
+		t.FailNow()
+	}
+
+	ownership := BeneficialOwnership{}
+	json.Unmarshal(res.Payload, &ownership)
+
+	return ownership
+
+}
+
+//addLegacyRecord writes a record as it was stored before shares existed
+func addLegacyRecord(stub *shim.MockStub, key string, record string) {
+
+	stub.MockTransactionStart("addLegacyRecord")
+	stub.PutState(key, []byte(record))
+	stub.MockTransactionEnd("addLegacyRecord")
+
+}
+
+func getBuyerOfferString() string {
+
+	return `{"saleDate":"2018-01-15T10:00:00","salePrice":2000,"owners":[{"id":"ownership_4","partyId":"party_4","share":"1"}]}`
+
+}
+
+func checkRegisterProperty(t *testing.T, stub *shim.MockStub, propertyId string, detailsString string){
+
+	registerArgs := getFourArgs(registerProperty, propertyId, detailsString)
+
+	message:= " | " + registerProperty + " with args: {" + string(registerArgs[2]) + ", " + string(registerArgs[3]) + "}, failed. "
+
+	res := stub.MockInvoke(registerProperty, registerArgs)
+	if res.Status != shim.OK {
+		message := message +  "[res.Message=" + res.Message + "]"
+		fmt.Println(message)
+		t.FailNow()
+	}
+
+}
+
+func getTestRegisteredProperty(txId string, propertyId string, details PropertyDetails) (Property, string) {
+
+	property := Property{}
+	property.TxId = txId
+	property.PropertyId = propertyId
+	property.Owners = []Attribute{}
+	property.ParcelNumber = details.ParcelNumber
+	property.LegalDescription = details.LegalDescription
+	property.Address = details.Address
+	property.LandArea = details.LandArea
+	property.Zoning = details.Zoning
+
+	propertyAsBytes, _ := getPropertyAsBytes(property)
+
+	return property, string(propertyAsBytes)
+
+}
+
+func getValidPropertyDetails() PropertyDetails {
+
+	details := PropertyDetails{}
+	details.ParcelNumber = "123-456-789"
+	details.LegalDescription = "Lot 4, Block 2, Riverside Addition"
+	details.Address = "12 Main Street, Springfield"
+	details.LandArea = 650.5
+	details.Zoning = "R-1"
+
+	return details
+
+}
+
+func getValidPropertyDetailsString() string {
+
+	detailsAsBytes, _ := json.Marshal(getValidPropertyDetails())
+
+	return string(detailsAsBytes)
+
+}
+
+//setCaller makes every following invocation come from identity
+func setCaller(identity string) {
+
+	getCallerId = func(stub shim.ChaincodeStubInterface) (string, error) {
+		return identity, nil
+	}
+
+}
+
+//getStubWithRole initialises the chaincode as admin, grants role to identity and
+//leaves identity as the caller
+func getStubWithRole(role string, identity string) (*shim.MockStub){
+
+	stub := getStub()
+
+	setCaller(admin)
+	stub.MockInit("init", [][]byte{[]byte("init")})
+
+	roleArgs := getFourArgs(assignRole, identity, role)
+	stub.MockInvoke(assignRole, roleArgs)
+
+	setCaller(identity)
+
+	return stub
+
+}
+
 func getStub() (*shim.MockStub){
 
 	scc := new(Chaincode)
 	stub := shim.NewMockStub("contract", scc)
 
+	addTestParties(stub)
+
 	return stub
 
+}
+
+//addTestParties registers verified parties party_1 to party_5 for the owners the tests use
+func addTestParties(stub *shim.MockStub) {
+
+	stub.MockTransactionStart("addTestParties")
+
+	for i := 1; i <= 5; i++ {
+		party := Party{}
+		party.PartyId = "party_" + strconv.Itoa(i)
+		party.Type = partyPerson
+		party.LegalName = "Test Party " + strconv.Itoa(i)
+		party.Identifiers = []PartyIdentifier{}
+		party.KycStatus = kycVerified
+		addPartyToLedger(stub, party)
+	}
+
+	stub.MockTransactionEnd("addTestParties")
+
 }
\ No newline at end of file